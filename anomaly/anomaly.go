@@ -0,0 +1,144 @@
+// Package anomaly spots risky lookup patterns
+// in the local audit log
+package anomaly
+
+import (
+	"fmt"
+	"synthera/audit"
+	"time"
+)
+
+// Kinds of finding, recorded as the Kind of audit.ActionAnomaly events.
+const (
+	KindNRICBurst      = "nric_burst"
+	KindRepeatSubject  = "repeat_subject"
+	KindRelationPaging = "relation_paging"
+)
+
+// Thresholds are how many lookups of each pattern within Window count as
+// unusual. Zero values take the defaults.
+type Thresholds struct {
+	Window        time.Duration
+	NRICBurst     int
+	RepeatLookups int
+	RelationPages int
+}
+
+// DefaultThresholds are loose enough that ordinary casework doesn't trip
+// them.
+var DefaultThresholds = Thresholds{
+	Window:        15 * time.Minute,
+	NRICBurst:     10,
+	RepeatLookups: 5,
+	RelationPages: 15,
+}
+
+// WithDefaults fills in the zero values of t.
+func (t Thresholds) WithDefaults() Thresholds {
+	if t.Window <= 0 {
+		t.Window = DefaultThresholds.Window
+	}
+	if t.NRICBurst <= 0 {
+		t.NRICBurst = DefaultThresholds.NRICBurst
+	}
+	if t.RepeatLookups <= 0 {
+		t.RepeatLookups = DefaultThresholds.RepeatLookups
+	}
+	if t.RelationPages <= 0 {
+		t.RelationPages = DefaultThresholds.RelationPages
+	}
+	return t
+}
+
+// Within tightens t to limit: no count above limit's and no window
+// shorter than it. Zero values in limit leave that threshold alone.
+func (t Thresholds) Within(limit Thresholds) Thresholds {
+	if limit.Window > 0 && t.Window < limit.Window {
+		t.Window = limit.Window
+	}
+	if limit.NRICBurst > 0 && t.NRICBurst > limit.NRICBurst {
+		t.NRICBurst = limit.NRICBurst
+	}
+	if limit.RepeatLookups > 0 && t.RepeatLookups > limit.RepeatLookups {
+		t.RepeatLookups = limit.RepeatLookups
+	}
+	if limit.RelationPages > 0 && t.RelationPages > limit.RelationPages {
+		t.RelationPages = limit.RelationPages
+	}
+	return t
+}
+
+// Finding is one pattern that crossed its threshold. RecordID is set for
+// repeated lookups of the same subject.
+type Finding struct {
+	Kind     string
+	Count    int
+	RecordID int
+	Message  string
+}
+
+// Detect looks for unusual patterns in userID's events within the window
+// before now. events must be oldest first, as audit.Read returns them. A
+// finding the user acknowledged (audit.ActionAnomalyAck) only counts the
+// events after it, so the same pattern isn't reported on every lookup.
+func Detect(events []audit.Event, userID int, now time.Time, t Thresholds) []Finding {
+	t = t.WithDefaults()
+	since := now.Add(-t.Window)
+	minutes := int(t.Window / time.Minute)
+
+	nric, pages := 0, 0
+	views := map[int]int{}
+	var order []int
+	for _, e := range events {
+		if e.UserID != userID || e.Time.Before(since) || e.Time.After(now) {
+			continue
+		}
+		switch {
+		case e.Action == audit.ActionLookup && e.Kind == audit.LookupNRIC:
+			nric++
+		case e.Action == audit.ActionLookup && e.Kind == audit.LookupRelations:
+			pages++
+		case e.Action == audit.ActionRecordView && e.RecordID != 0:
+			if _, seen := views[e.RecordID]; !seen {
+				order = append(order, e.RecordID)
+			}
+			views[e.RecordID]++
+		case e.Action == audit.ActionAnomalyAck:
+			switch e.Kind {
+			case KindNRICBurst:
+				nric = 0
+			case KindRelationPaging:
+				pages = 0
+			case KindRepeatSubject:
+				views[e.RecordID] = 0
+			}
+		}
+	}
+
+	var findings []Finding
+	if nric >= t.NRICBurst {
+		findings = append(findings, Finding{
+			Kind:    KindNRICBurst,
+			Count:   nric,
+			Message: fmt.Sprintf("%d Mykad searches in the last %d minutes", nric, minutes),
+		})
+	}
+	for _, id := range order {
+		if views[id] >= t.RepeatLookups {
+			findings = append(findings, Finding{
+				Kind:     KindRepeatSubject,
+				Count:    views[id],
+				RecordID: id,
+				Message:  fmt.Sprintf("The same record (ID %d) opened %d times in the last %d minutes", id, views[id], minutes),
+			})
+		}
+	}
+	if pages >= t.RelationPages {
+		findings = append(findings, Finding{
+			Kind:    KindRelationPaging,
+			Count:   pages,
+			Message: fmt.Sprintf("%d pages of relationships in the last %d minutes", pages, minutes),
+		})
+	}
+	return findings
+}
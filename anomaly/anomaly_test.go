@@ -0,0 +1,125 @@
+package anomaly
+
+import (
+	"reflect"
+	"synthera/audit"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+func lookups(n int, kind string, userID int, age time.Duration) []audit.Event {
+	var events []audit.Event
+	for i := range n {
+		events = append(events, audit.Event{
+			Time:   now.Add(-age + time.Duration(i)*time.Second),
+			Action: audit.ActionLookup,
+			Kind:   kind,
+			UserID: userID,
+		})
+	}
+	return events
+}
+
+func views(n, recordID, userID int, age time.Duration) []audit.Event {
+	var events []audit.Event
+	for i := range n {
+		events = append(events, audit.Event{
+			Time:     now.Add(-age + time.Duration(i)*time.Second),
+			Action:   audit.ActionRecordView,
+			UserID:   userID,
+			RecordID: recordID,
+		})
+	}
+	return events
+}
+
+func ack(kind string, recordID int, age time.Duration) audit.Event {
+	return audit.Event{Time: now.Add(-age), Action: audit.ActionAnomalyAck, Kind: kind, UserID: 1, RecordID: recordID}
+}
+
+func concat(parts ...[]audit.Event) []audit.Event {
+	var events []audit.Event
+	for _, p := range parts {
+		events = append(events, p...)
+	}
+	return events
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []audit.Event
+		t      Thresholds
+		want   []string
+	}{
+		{
+			name:   "quiet",
+			events: lookups(9, audit.LookupNRIC, 1, time.Minute),
+		},
+		{
+			name:   "nric burst",
+			events: lookups(10, audit.LookupNRIC, 1, time.Minute),
+			want:   []string{KindNRICBurst},
+		},
+		{
+			name:   "outside the window",
+			events: lookups(10, audit.LookupNRIC, 1, 20*time.Minute),
+		},
+		{
+			name:   "other operator",
+			events: lookups(10, audit.LookupNRIC, 2, time.Minute),
+		},
+		{
+			name:   "repeat subject",
+			events: concat(views(5, 42, 1, time.Minute), views(4, 43, 1, time.Minute)),
+			want:   []string{KindRepeatSubject},
+		},
+		{
+			name:   "relation paging",
+			events: lookups(15, audit.LookupRelations, 1, time.Minute),
+			want:   []string{KindRelationPaging},
+		},
+		{
+			name:   "acknowledged burst restarts the count",
+			events: concat(lookups(10, audit.LookupNRIC, 1, 5*time.Minute), []audit.Event{ack(KindNRICBurst, 0, 4*time.Minute)}, lookups(3, audit.LookupNRIC, 1, time.Minute)),
+		},
+		{
+			name:   "acknowledging one record leaves another",
+			events: concat(views(5, 42, 1, 5*time.Minute), views(5, 43, 1, 5*time.Minute), []audit.Event{ack(KindRepeatSubject, 42, 4*time.Minute)}),
+			want:   []string{KindRepeatSubject},
+		},
+		{
+			name:   "custom thresholds",
+			events: lookups(3, audit.LookupNRIC, 1, time.Minute),
+			t:      Thresholds{NRICBurst: 3},
+			want:   []string{KindNRICBurst},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, f := range Detect(tt.events, 1, now, tt.t) {
+				got = append(got, f.Kind)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Detect = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithin(t *testing.T) {
+	limit := Thresholds{Window: 30 * time.Minute, NRICBurst: 5}
+	got := DefaultThresholds.Within(limit)
+	want := Thresholds{Window: 30 * time.Minute, NRICBurst: 5, RepeatLookups: 5, RelationPages: 15}
+	if got != want {
+		t.Errorf("defaults within %+v = %+v, want %+v", limit, got, want)
+	}
+
+	strict := Thresholds{Window: time.Hour, NRICBurst: 2, RepeatLookups: 2, RelationPages: 2}
+	if got := strict.Within(limit); got != strict {
+		t.Errorf("stricter thresholds loosened to %+v", got)
+	}
+}
@@ -2,11 +2,15 @@ package api
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -18,52 +22,148 @@ func init() {
 	}
 }
 
-func NewClient(token string) *Client {
-	return &Client{
+type Option func(*Client)
+
+// WithBaseURL points the client at a different backend, such as the
+// mock server in synthera/api/mock.
+func WithBaseURL(url string) Option {
+	return func(c *Client) {
+		c.baseURL = url
+	}
+}
+
+// WithDebugLog logs the method, endpoint, status and latency of every
+// request. Tokens and bodies are never logged.
+func WithDebugLog(l *log.Logger) Option {
+	return func(c *Client) {
+		c.debug = l
+	}
+}
+
+// WithContext ties every request to ctx, so cancelling it aborts whatever
+// is in flight, for example when the user quits or the process is signalled.
+func WithContext(ctx context.Context) Option {
+	return func(c *Client) {
+		c.ctx = ctx
+	}
+}
+
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
 		apiToken: token,
+		baseURL:  baseURL,
+		ctx:      context.Background(),
+	}
+	for _, opt := range opts {
+		opt(c)
 	}
+	return c
+}
+
+func (c *Client) BaseURL() string {
+	return c.baseURL
 }
 
 func (c *Client) makeRequest(method, endpoint string, requestBody, response any) error {
-	var reqBody io.Reader
+	var bodyBytes []byte
 	if requestBody != nil {
-		bodyBytes, err := json.Marshal(requestBody)
+		var err error
+		bodyBytes, err = json.Marshal(requestBody)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request body: %w", err)
 		}
-		reqBody = bytes.NewBuffer(bodyBytes)
 	}
 
-	req, err := http.NewRequest(method, fmt.Sprintf("%s%s", baseURL, endpoint), reqBody)
+	resp, err := c.send(method, endpoint, bodyBytes)
 	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+		return err
 	}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("X-API-Token", c.apiToken)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to execute requst: %w", err)
+	// A 401 right after our estimate of the server clock moved is most
+	// likely a stale signature timestamp, so sign again and retry once.
+	if c.signer != nil && c.signer.observe(resp) && resp.StatusCode == http.StatusUnauthorized {
+		resp.Body.Close()
+		resp, err = c.send(method, endpoint, bodyBytes)
+		if err != nil {
+			return err
+		}
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusUpgradeRequired {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("%w: %s", ErrIncompatibleVersion, strings.TrimSpace(string(bodyBytes)))
+	}
+	if v := resp.Header.Get("API-Version"); v != "" && v != APIVersion {
+		return fmt.Errorf("%w: backend speaks API version %s, this build speaks %s", ErrIncompatibleVersion, v, APIVersion)
+	}
+
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("API returned non-OK status: %d - %s", resp.StatusCode, string(bodyBytes))
+		return &StatusError{Code: resp.StatusCode, Body: string(bodyBytes)}
 	}
 
 	if response != nil {
-		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("failed to read response: %w", err)
+		}
+		unknown, err := decodeStrict(endpoint, data, response, c.hidden)
+		// data may hold fields the policy withholds, which decoding
+		// dropped; don't leave them lying around in the buffer either.
+		clear(data)
+		if len(unknown) > 0 && c.debug != nil {
+			c.debug.Printf("%s %s: ignoring fields this build doesn't know: %s", method, endpoint, strings.Join(unknown, ", "))
+		}
+		if err != nil {
 			return fmt.Errorf("failed to decode response: %w", err)
 		}
 	}
 	return nil
 }
 
+func (c *Client) send(method, endpoint string, body []byte) (*http.Response, error) {
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(c.ctx, method, fmt.Sprintf("%s%s", c.baseURL, endpoint), reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Version", APIVersion)
+	req.Header.Set("User-Agent", userAgent())
+	req.Header.Set("X-API-Token", c.apiToken)
+	if c.signer != nil {
+		if err := c.signer.sign(req, body); err != nil {
+			return nil, err
+		}
+	}
+
+	start := time.Now()
+	resp, err := c.httpClient.Do(req)
+	if c.debug != nil {
+		if err != nil {
+			c.debug.Printf("%s %s failed after %s: %v", method, endpoint, time.Since(start).Round(time.Millisecond), err)
+		} else {
+			c.debug.Printf("%s %s -> %d in %s", method, endpoint, resp.StatusCode, time.Since(start).Round(time.Millisecond))
+		}
+	}
+	if err != nil {
+		if errors.Is(err, ErrPinMismatch) {
+			return nil, fmt.Errorf("%w: the backend's certificate does not match any pinned key, the connection may be intercepted", ErrPinMismatch)
+		}
+		return nil, fmt.Errorf("failed to execute requst: %w", err)
+	}
+	return resp, nil
+}
+
 func (c *Client) TraceName(name string, page int) ([]TraceNameItem, error) {
 	req := TraceNameRequest{
 		Name: name,
@@ -78,8 +178,16 @@ func (c *Client) TraceName(name string, page int) ([]TraceNameItem, error) {
 }
 
 func (c *Client) TraceDetail(id int) ([]TraceDetailID, error) {
+	return c.TraceDetailApproved(id, 0)
+}
+
+// TraceDetailApproved is TraceDetail under an approved request, for
+// backends whose policy requires approvals.
+func (c *Client) TraceDetailApproved(id, approvalID int) ([]TraceDetailID, error) {
 	req := TraceDetailRequest{
-		ID: id,
+		ID:         id,
+		ApprovalID: approvalID,
+		Fields:     c.allowedFields(),
 	}
 	var res TraceDetailResponse
 	err := c.makeRequest("POST", "/trace/id", req, &res)
@@ -91,9 +199,17 @@ func (c *Client) TraceDetail(id int) ([]TraceDetailID, error) {
 }
 
 func (c *Client) TraceRelations(id int, offset int) ([]TraceDetailID, TraceRelationsItem, error) {
+	return c.TraceRelationsApproved(id, offset, 0)
+}
+
+// TraceRelationsApproved is TraceRelations under an approved request, for
+// backends whose policy requires approvals.
+func (c *Client) TraceRelationsApproved(id, offset, approvalID int) ([]TraceDetailID, TraceRelationsItem, error) {
 	req := TraceRelationsRequest{
-		ID:     id,
-		Offset: offset,
+		ID:         id,
+		Offset:     offset,
+		ApprovalID: approvalID,
+		Fields:     c.allowedFields(),
 	}
 
 	var res TraceRelationsResponse
@@ -106,8 +222,14 @@ func (c *Client) TraceRelations(id int, offset int) ([]TraceDetailID, TraceRelat
 }
 
 func (c *Client) TraceNRIC(nric string) ([]TraceDetailID, error) {
+	return c.TraceNRICApproved(nric, 0)
+}
+
+func (c *Client) TraceNRICApproved(nric string, approvalID int) ([]TraceDetailID, error) {
 	req := TraceNRICRequest{
-		NRIC: nric,
+		NRIC:       nric,
+		ApprovalID: approvalID,
+		Fields:     c.allowedFields(),
 	}
 	var res TraceNRICResponse
 	err := c.makeRequest("POST", "/trace/nric", req, &res)
@@ -115,7 +237,6 @@ func (c *Client) TraceNRIC(nric string) ([]TraceDetailID, error) {
 		return nil, err
 	}
 
-	fmt.Println(res.Data)
 	return res.Data, nil
 }
 
@@ -130,3 +251,276 @@ func (c *Client) History(page int) ([]HistoryItem, error) {
 	}
 	return res.Data, nil
 }
+
+// maxHistoryPages bounds AllHistory so a misbehaving backend that never
+// returns an empty page cannot keep the client looping forever.
+const maxHistoryPages = 1000
+
+// ErrHistoryTruncated means AllHistory stopped at maxHistoryPages before
+// reaching the end of the history.
+var ErrHistoryTruncated = fmt.Errorf("history is longer than %d pages, only those were read", maxHistoryPages)
+
+// AllHistory reads every page of history. If there are more than
+// maxHistoryPages it returns what it read along with ErrHistoryTruncated.
+func (c *Client) AllHistory() ([]HistoryItem, error) {
+	var all []HistoryItem
+	for page := 1; page <= maxHistoryPages; page++ {
+		items, err := c.History(page)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) == 0 {
+			return all, nil
+		}
+		all = append(all, items...)
+	}
+	return all, ErrHistoryTruncated
+}
+
+func (c *Client) Account() (User, error) {
+	var res AccountResponse
+	err := c.makeRequest("POST", "/account", nil, &res)
+	if err != nil {
+		return User{}, err
+	}
+	return res.User, nil
+}
+
+func (c *Client) TeamMembers() ([]TeamMember, error) {
+	var res TeamMembersResponse
+	err := c.makeRequest("POST", "/team/members", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// IssueMemberToken returns the new token in plain text. The backend only
+// ever hands it out once, so callers must show it straight away.
+func (c *Client) IssueMemberToken(memberID int) (string, TokenInfo, error) {
+	req := TeamTokenRequest{
+		MemberID: memberID,
+	}
+	var res IssueTokenResponse
+	err := c.makeRequest("POST", "/team/tokens/issue", req, &res)
+	if err != nil {
+		return "", TokenInfo{}, err
+	}
+	return res.Token, res.Info, nil
+}
+
+func (c *Client) RevokeMemberToken(memberID, tokenID int) (TeamMember, error) {
+	req := TeamTokenRequest{
+		MemberID: memberID,
+		TokenID:  tokenID,
+	}
+	return c.updateMember("/team/tokens/revoke", req)
+}
+
+func (c *Client) SetMemberLimit(memberID int, limit float64) (TeamMember, error) {
+	req := TeamLimitRequest{
+		MemberID:   memberID,
+		SpendLimit: limit,
+	}
+	return c.updateMember("/team/limit", req)
+}
+
+func (c *Client) SuspendMember(memberID int, suspended bool) (TeamMember, error) {
+	req := TeamSuspendRequest{
+		MemberID:  memberID,
+		Suspended: suspended,
+	}
+	return c.updateMember("/team/suspend", req)
+}
+
+func (c *Client) updateMember(endpoint string, req any) (TeamMember, error) {
+	var res TeamMemberResponse
+	err := c.makeRequest("POST", endpoint, req, &res)
+	if err != nil {
+		return TeamMember{}, err
+	}
+	return res.Data, nil
+}
+
+func (c *Client) Tokens() ([]TokenInfo, error) {
+	var res TokensResponse
+	err := c.makeRequest("POST", "/tokens", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// RotateToken issues a replacement for the token the client is using and
+// revokes the old one, so callers must switch to the returned token, and
+// to the returned signing secret when it isn't empty.
+func (c *Client) RotateToken() (token, secret string, err error) {
+	var res IssueTokenResponse
+	err = c.makeRequest("POST", "/tokens/rotate", nil, &res)
+	if err != nil {
+		return "", "", err
+	}
+	return res.Token, res.SigningSecret, nil
+}
+
+func (c *Client) RevokeToken(tokenID int) error {
+	req := RevokeTokenRequest{
+		TokenID: tokenID,
+	}
+	return c.makeRequest("POST", "/tokens/revoke", req, nil)
+}
+
+func (c *Client) RevokeCurrentToken() error {
+	req := RevokeTokenRequest{
+		Current: true,
+	}
+	return c.makeRequest("POST", "/tokens/revoke", req, nil)
+}
+
+// Policy fetches the settings admins have made mandatory.
+func (c *Client) Policy() (Policy, error) {
+	var res PolicyResponse
+	err := c.makeRequest("POST", "/policy", nil, &res)
+	if err != nil {
+		return Policy{}, err
+	}
+	return res.Data, nil
+}
+
+// Rules fetches the acceptable-use rules for lookups.
+func (c *Client) Rules() (Rules, error) {
+	var res RulesResponse
+	err := c.makeRequest("POST", "/policy/rules", nil, &res)
+	if err != nil {
+		return Rules{}, err
+	}
+	return res.Data, nil
+}
+
+// AUP fetches the current acceptable-use policy.
+func (c *Client) AUP() (AUP, error) {
+	var res AUPResponse
+	err := c.makeRequest("POST", "/aup", nil, &res)
+	if err != nil {
+		return AUP{}, err
+	}
+	return res.Data, nil
+}
+
+// AcceptAUP records that the user accepted version of the policy. The
+// backend refuses a version that is no longer current.
+func (c *Client) AcceptAUP(version string) (AUPAcceptance, error) {
+	req := AcceptAUPRequest{
+		Version: version,
+	}
+	var res AUPAcceptanceResponse
+	err := c.makeRequest("POST", "/aup/accept", req, &res)
+	if err != nil {
+		return AUPAcceptance{}, err
+	}
+	return res.Data, nil
+}
+
+// ReportAnomaly passes an unusual lookup pattern on to org admins.
+func (c *Client) ReportAnomaly(r AnomalyReport) error {
+	return c.makeRequest("POST", "/anomalies/report", r, nil)
+}
+
+// SubmitApproval asks a supervisor to sign off a lookup. The returned
+// approval is pending until one decides.
+func (c *Client) SubmitApproval(req SubmitApprovalRequest) (Approval, error) {
+	var res ApprovalResponse
+	err := c.makeRequest("POST", "/approvals/submit", req, &res)
+	if err != nil {
+		return Approval{}, err
+	}
+	return res.Data, nil
+}
+
+// MyApprovals lists the caller's own requests, newest first.
+func (c *Client) MyApprovals() ([]Approval, error) {
+	var res ApprovalsResponse
+	err := c.makeRequest("POST", "/approvals/mine", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// PendingApprovals lists other operators' requests waiting for a decision.
+// Only supervisors may call it.
+func (c *Client) PendingApprovals() ([]Approval, error) {
+	var res ApprovalsResponse
+	err := c.makeRequest("POST", "/approvals/pending", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+func (c *Client) DecideApproval(id int, approve bool) (Approval, error) {
+	req := DecideApprovalRequest{
+		ID:      id,
+		Approve: approve,
+	}
+	var res ApprovalResponse
+	err := c.makeRequest("POST", "/approvals/decide", req, &res)
+	if err != nil {
+		return Approval{}, err
+	}
+	return res.Data, nil
+}
+
+// SubmitReport flags fields of a record as inaccurate for the data team
+// to check.
+func (c *Client) SubmitReport(req SubmitReportRequest) (Report, error) {
+	var res ReportResponse
+	err := c.makeRequest("POST", "/reports/submit", req, &res)
+	if err != nil {
+		return Report{}, err
+	}
+	return res.Data, nil
+}
+
+// MyReports lists the caller's inaccuracy reports, newest first.
+func (c *Client) MyReports() ([]Report, error) {
+	var res ReportsResponse
+	err := c.makeRequest("POST", "/reports/mine", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+	return res.Data, nil
+}
+
+// EnrollTOTP starts enrolling an authenticator app. The secret is only
+// ever returned here; enrollment takes effect once ConfirmTOTP accepts a
+// code generated from it.
+func (c *Client) EnrollTOTP() (secret, uri string, err error) {
+	var res EnrollTOTPResponse
+	err = c.makeRequest("POST", "/totp/enroll", nil, &res)
+	if err != nil {
+		return "", "", err
+	}
+	return res.Secret, res.URI, nil
+}
+
+func (c *Client) ConfirmTOTP(code string) error {
+	req := TOTPCodeRequest{
+		Code: code,
+	}
+	return c.makeRequest("POST", "/totp/confirm", req, nil)
+}
+
+// VerifyStepUp trades a one-time code for a grace window in which detail
+// lookups are allowed.
+func (c *Client) VerifyStepUp(code string) (time.Duration, error) {
+	req := TOTPCodeRequest{
+		Code: code,
+	}
+	var res StepUpResponse
+	err := c.makeRequest("POST", "/totp/verify", req, &res)
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(res.GraceSeconds) * time.Second, nil
+}
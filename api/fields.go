@@ -0,0 +1,64 @@
+package api
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+var recordType = reflect.TypeOf(TraceDetailID{})
+
+// RecordFields lists the JSON names of the TraceDetailID fields a field
+// policy can withhold. The ID is always sent, lookups depend on it.
+func RecordFields() []string {
+	var names []string
+	for i := 0; i < recordType.NumField(); i++ {
+		name, _, _ := strings.Cut(recordType.Field(i).Tag.Get("json"), ",")
+		if name != "id" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Withholding returns a copy of c for record lookups under a field
+// policy. It asks the backend for only the fields not in hidden, and drops
+// any hidden field the backend sends anyway while decoding, so it never
+// reaches a TraceDetailID. Names that aren't record fields are ignored.
+func (c *Client) Withholding(hidden []string) *Client {
+	cc := *c
+	cc.hidden = map[string]bool{}
+	for _, name := range RecordFields() {
+		if slices.Contains(hidden, name) {
+			cc.hidden[name] = true
+		}
+	}
+	return &cc
+}
+
+// allowedFields is the data-minimisation parameter sent with lookups. It
+// is nil, asking for everything, when nothing is withheld.
+func (c *Client) allowedFields() []string {
+	if len(c.hidden) == 0 {
+		return nil
+	}
+	return slices.DeleteFunc(RecordFields(), func(name string) bool { return c.hidden[name] })
+}
+
+// Withheld lists the record fields c drops, in record order.
+func (c *Client) Withheld() []string {
+	return slices.DeleteFunc(RecordFields(), func(name string) bool { return !c.hidden[name] })
+}
+
+// FieldPolicy fetches the record fields withheld from role.
+func (c *Client) FieldPolicy(role string) (FieldPolicy, error) {
+	req := FieldPolicyRequest{
+		Role: role,
+	}
+	var res FieldPolicyResponse
+	err := c.makeRequest("POST", "/policy/fields", req, &res)
+	if err != nil {
+		return FieldPolicy{}, err
+	}
+	return res.Data, nil
+}
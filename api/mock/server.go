@@ -0,0 +1,1046 @@
+// Package mock provides an in-memory Synthera backend
+// for exercising the API client without the real service
+package mock
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"time"
+
+	"synthera/api"
+	"synthera/rules"
+	"synthera/totp"
+)
+
+// Server is an httptest.Server speaking the same JSON contract as the
+// Synthera workers backend. Pass Server.URL to api.WithBaseURL.
+type Server struct {
+	*httptest.Server
+
+	mu      sync.Mutex
+	members map[int]*member
+	tokens  map[string]tokenRef
+	records []api.TraceDetailID
+	history map[int][]api.HistoryItem
+	policy  api.Policy
+	rules   *api.Rules
+	aup     *api.AUP
+	nextID  int
+
+	// relations maps a record ID to its relationships, one per page.
+	relations map[int][]api.TraceRelationsItem
+
+	// hiddenFields maps a role to the record fields withheld from it.
+	hiddenFields map[string][]string
+
+	approvals []*approval
+	anomalies []api.AnomalyReport
+	reports   []*report
+
+	// caller is the token behind the request currently being handled.
+	// Handlers run with mu held, so it is stable for their duration.
+	caller tokenRef
+
+	secrets map[string][]byte
+	nonces  map[string]time.Time
+
+	// APIVersion is the contract version the server speaks. Requests
+	// asking for a different one get 426 Upgrade Required.
+	APIVersion string
+
+	// Now is the server clock. Tests can skew it to exercise timestamp
+	// checks; it also drives the Date header clients sync against.
+	Now func() time.Time
+}
+
+type tokenRef struct {
+	memberID int
+	tokenID  int
+}
+
+type approval struct {
+	api.Approval
+	requesterID int
+}
+
+type report struct {
+	api.Report
+	reporterID int
+}
+
+type member struct {
+	api.TeamMember
+	balance float64
+
+	// totpSecret is set on enrollment and totpEnabled once a code from it
+	// is confirmed. totpStep is the last step accepted, so codes can't be
+	// replayed, and stepUpUntil ends the current grace window.
+	totpSecret  []byte
+	totpEnabled bool
+	totpStep    int64
+	stepUpUntil time.Time
+
+	aupVersion string
+
+	// lookups are when the member's trace calls of the last hour ran,
+	// for the rate rule.
+	lookups []time.Time
+}
+
+// defaultStepUpGrace applies when the policy doesn't set a grace window.
+const defaultStepUpGrace = 5 * time.Minute
+
+type handlerFunc func(m *member, body []byte) (any, error)
+
+type httpError struct {
+	status  int
+	message string
+}
+
+func (e httpError) Error() string { return e.message }
+
+func errorf(status int, format string, a ...any) error {
+	return httpError{status: status, message: fmt.Sprintf(format, a...)}
+}
+
+func NewServer() *Server {
+	s := &Server{
+		members: map[int]*member{},
+		tokens:  map[string]tokenRef{},
+		history: map[int][]api.HistoryItem{},
+		secrets: map[string][]byte{},
+		nonces:  map[string]time.Time{},
+		nextID:  1,
+
+		APIVersion: api.APIVersion,
+		Now:        time.Now,
+	}
+
+	mux := http.NewServeMux()
+	s.route(mux, "/account", s.account)
+	s.route(mux, "/history", s.historyPage)
+	s.route(mux, "/trace/name", s.traceName)
+	s.route(mux, "/trace/id", s.traceID)
+	s.route(mux, "/trace/nric", s.traceNRIC)
+	s.route(mux, "/trace/relations", s.traceRelations)
+	s.route(mux, "/team/members", s.admin(s.teamMembers))
+	s.route(mux, "/team/tokens/issue", s.admin(s.issueToken))
+	s.route(mux, "/team/tokens/revoke", s.admin(s.revokeToken))
+	s.route(mux, "/team/limit", s.admin(s.setLimit))
+	s.route(mux, "/team/suspend", s.admin(s.suspend))
+	s.route(mux, "/tokens", s.listTokens)
+	s.route(mux, "/tokens/rotate", s.rotateToken)
+	s.route(mux, "/tokens/revoke", s.revokeOwnToken)
+	s.route(mux, "/policy", s.getPolicy)
+	s.route(mux, "/policy/fields", s.getFieldPolicy)
+	s.route(mux, "/policy/rules", s.getRules)
+	s.route(mux, "/totp/enroll", s.enrollTOTP)
+	s.route(mux, "/totp/confirm", s.confirmTOTP)
+	s.route(mux, "/totp/verify", s.verifyTOTP)
+	s.route(mux, "/aup", s.getAUP)
+	s.route(mux, "/aup/accept", s.acceptAUP)
+	s.route(mux, "/anomalies/report", s.reportAnomaly)
+	s.route(mux, "/approvals/submit", s.submitApproval)
+	s.route(mux, "/approvals/mine", s.myApprovals)
+	s.route(mux, "/approvals/pending", s.admin(s.pendingApprovals))
+	s.route(mux, "/approvals/decide", s.admin(s.decideApproval))
+	s.route(mux, "/reports/submit", s.submitReport)
+	s.route(mux, "/reports/mine", s.myReports)
+
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// AddMember registers a user and returns an API token for them.
+func (s *Server) AddMember(name, email, role string, balance float64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.members[id] = &member{
+		TeamMember: api.TeamMember{
+			ID:    id,
+			Name:  name,
+			Email: email,
+			Role:  role,
+		},
+		balance: balance,
+	}
+	token, _ := s.newTokenLocked(id)
+	return token
+}
+
+// RequireSigning makes the server reject unsigned requests made with token
+// and returns the HMAC secret the client should be configured with.
+func (s *Server) RequireSigning(token string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	s.secrets[token] = secret
+	return secret
+}
+
+func (s *Server) verifyLocked(token string, r *http.Request, body []byte) error {
+	secret, ok := s.secrets[token]
+	if !ok {
+		return nil
+	}
+
+	now := s.Now()
+	if err := api.VerifySignature(r, body, secret, now, api.DefaultMaxSkew); err != nil {
+		return err
+	}
+
+	for nonce, seen := range s.nonces {
+		if now.Sub(seen) > 2*api.DefaultMaxSkew {
+			delete(s.nonces, nonce)
+		}
+	}
+	nonce := r.Header.Get(api.HeaderNonce)
+	if _, replayed := s.nonces[nonce]; replayed {
+		return fmt.Errorf("nonce %s already used", nonce)
+	}
+	s.nonces[nonce] = now
+	return nil
+}
+
+// AddRecord seeds a person record that the trace endpoints can return.
+func (s *Server) AddRecord(rec api.TraceDetailID) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.records = append(s.records, rec)
+}
+
+// SetPolicy replaces the organisation policy every member receives.
+func (s *Server) SetPolicy(p api.Policy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+}
+
+// SetRules sets the acceptable-use rules every member receives. Until it is
+// called the server answers 404, like a backend without rules.
+func (s *Server) SetRules(r api.Rules) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules = &r
+}
+
+// AddRelation links two seeded records. Each relation of id is one page of
+// its relationships, in the order added.
+func (s *Server) AddRelation(id, relatedID int, relation string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.relations == nil {
+		s.relations = map[int][]api.TraceRelationsItem{}
+	}
+	s.relations[id] = append(s.relations[id], api.TraceRelationsItem{
+		UserID:        id,
+		RelatedUserID: relatedID,
+		Relation:      relation,
+	})
+}
+
+// SetHiddenFields sets the record fields, by JSON name, withheld from role.
+// The server only reports them; like a backend that trusts the client, it
+// leaves out just what a lookup's fields parameter doesn't ask for.
+func (s *Server) SetHiddenFields(role string, fields ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hiddenFields == nil {
+		s.hiddenFields = map[string][]string{}
+	}
+	s.hiddenFields[role] = fields
+}
+
+// SetAUP publishes a version of the acceptable-use policy. Until it is
+// called the server answers 404, like a backend without one.
+func (s *Server) SetAUP(version, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.aup = &api.AUP{Version: version, Text: text}
+}
+
+// Anomalies returns the anomaly reports received so far.
+func (s *Server) Anomalies() []api.AnomalyReport {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Clone(s.anomalies)
+}
+
+// ResolveReport closes inaccuracy report id as the data team would, with
+// status api.ReportCorrected or api.ReportDismissed.
+func (s *Server) ResolveReport(id int, status, resolution string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := s.reports[id-1]
+	r.Status = status
+	r.Resolution = resolution
+	r.UpdatedAt = s.Now().UTC()
+}
+
+// TOTPCode returns the current one-time code for the member owning token,
+// as their authenticator app would show it.
+func (s *Server) TOTPCode(token string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return totp.Code(s.members[s.tokens[token].memberID].totpSecret, s.Now())
+}
+
+// AddHistory seeds search history for the member owning token.
+func (s *Server) AddHistory(token string, items ...api.HistoryItem) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.tokens[token].memberID
+	s.history[id] = append(s.history[id], items...)
+}
+
+func (s *Server) route(mux *http.ServeMux, path string, h handlerFunc) {
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(r.Body)
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		w.Header().Set("Date", s.Now().UTC().Format(http.TimeFormat))
+		w.Header().Set("API-Version", s.APIVersion)
+		if v := r.Header.Get("Accept-Version"); v != s.APIVersion {
+			http.Error(w, fmt.Sprintf("client speaks API version %q, server requires %q", v, s.APIVersion), http.StatusUpgradeRequired)
+			return
+		}
+
+		token := r.Header.Get("X-API-Token")
+		ref, ok := s.tokens[token]
+		if !ok {
+			http.Error(w, "invalid token", http.StatusUnauthorized)
+			return
+		}
+		if err := s.verifyLocked(token, r, body); err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		m := s.members[ref.memberID]
+		if m.Suspended {
+			http.Error(w, "account suspended", http.StatusForbidden)
+			return
+		}
+		s.caller = ref
+		for i := range m.Tokens {
+			if m.Tokens[i].ID == ref.tokenID {
+				m.Tokens[i].LastUsedAt = time.Now().UTC()
+			}
+		}
+
+		res, err := h(m, body)
+		if err != nil {
+			status := http.StatusBadRequest
+			if he, ok := err.(httpError); ok {
+				status = he.status
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(res)
+	})
+}
+
+func (s *Server) admin(h handlerFunc) handlerFunc {
+	return func(m *member, body []byte) (any, error) {
+		if !s.userLocked(m).CanManageTeam() {
+			return nil, errorf(http.StatusForbidden, "role %q cannot manage the team", m.Role)
+		}
+		return h(m, body)
+	}
+}
+
+func (s *Server) userLocked(m *member) api.User {
+	return api.User{
+		ID:          m.ID,
+		Name:        m.Name,
+		Role:        m.Role,
+		Balance:     m.balance,
+		TOTPEnabled: m.totpEnabled,
+	}
+}
+
+func (s *Server) newTokenLocked(memberID int) (string, api.TokenInfo) {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	token := hex.EncodeToString(buf)
+
+	info := api.TokenInfo{
+		ID:        s.nextID,
+		Prefix:    token[:6],
+		CreatedAt: time.Now().UTC(),
+	}
+	s.nextID++
+
+	m := s.members[memberID]
+	m.Tokens = append(m.Tokens, info)
+	s.tokens[token] = tokenRef{memberID: memberID, tokenID: info.ID}
+	return token, info
+}
+
+func (s *Server) memberLocked(id int) (*member, error) {
+	m, ok := s.members[id]
+	if !ok {
+		return nil, errorf(http.StatusNotFound, "member %d not found", id)
+	}
+	return m, nil
+}
+
+// manageableLocked returns member id if caller may manage them: themselves,
+// or anyone of lower rank. Admins manage members and owners manage admins,
+// so no admin can act on another admin or on the owner.
+func (s *Server) manageableLocked(caller *member, id int) (*member, error) {
+	target, err := s.memberLocked(id)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID != caller.ID && rank(target.Role) >= rank(caller.Role) {
+		return nil, errorf(http.StatusForbidden, "%s %s cannot be managed by %s %s", target.Role, target.Name, caller.Role, caller.Name)
+	}
+	return target, nil
+}
+
+func rank(role string) int {
+	switch role {
+	case api.RoleOwner:
+		return 2
+	case api.RoleAdmin:
+		return 1
+	}
+	return 0
+}
+
+func (s *Server) account(m *member, _ []byte) (any, error) {
+	return api.AccountResponse{User: s.userLocked(m)}, nil
+}
+
+func (s *Server) getPolicy(_ *member, _ []byte) (any, error) {
+	return api.PolicyResponse{Data: s.policy}, nil
+}
+
+func (s *Server) getRules(_ *member, _ []byte) (any, error) {
+	if s.rules == nil {
+		return nil, errorf(http.StatusNotFound, "no rules")
+	}
+	return api.RulesResponse{Data: *s.rules}, nil
+}
+
+func (s *Server) getAUP(m *member, _ []byte) (any, error) {
+	if s.aup == nil {
+		return nil, errorf(http.StatusNotFound, "no acceptable-use policy")
+	}
+	aup := *s.aup
+	aup.AcceptedVersion = m.aupVersion
+	return api.AUPResponse{Data: aup}, nil
+}
+
+func (s *Server) acceptAUP(m *member, body []byte) (any, error) {
+	var req api.AcceptAUPRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if s.aup == nil {
+		return nil, errorf(http.StatusNotFound, "no acceptable-use policy")
+	}
+	if req.Version != s.aup.Version {
+		return nil, errorf(http.StatusConflict, "version %s is not the current policy, read version %s", req.Version, s.aup.Version)
+	}
+	m.aupVersion = req.Version
+	return api.AUPAcceptanceResponse{Data: api.AUPAcceptance{
+		UserID:     m.ID,
+		Version:    req.Version,
+		AcceptedAt: s.Now().UTC(),
+	}}, nil
+}
+
+func (s *Server) reportAnomaly(_ *member, body []byte) (any, error) {
+	var req api.AnomalyReport
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	s.anomalies = append(s.anomalies, req)
+	return struct{}{}, nil
+}
+
+func (s *Server) getFieldPolicy(m *member, body []byte) (any, error) {
+	var req api.FieldPolicyRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Role != m.Role {
+		return nil, errorf(http.StatusForbidden, "you can only fetch the policy for your own role")
+	}
+	hidden := s.hiddenFields[m.Role]
+	if hidden == nil {
+		hidden = []string{}
+	}
+	return api.FieldPolicyResponse{Data: api.FieldPolicy{Role: m.Role, Hidden: hidden}}, nil
+}
+
+func (s *Server) historyPage(m *member, body []byte) (any, error) {
+	var req api.HistoryRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	const perPage = 10
+	items := s.history[m.ID]
+	start := (req.Page - 1) * perPage
+	if req.Page < 1 || start >= len(items) {
+		return api.HistoryResponse{Data: []api.HistoryItem{}}, nil
+	}
+	end := min(start+perPage, len(items))
+	return api.HistoryResponse{Data: items[start:end]}, nil
+}
+
+func (s *Server) traceName(m *member, body []byte) (any, error) {
+	var req api.TraceNameRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	if err := s.aupLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.rulesLocked(m); err != nil {
+		return nil, err
+	}
+	s.countLookupLocked(m)
+
+	res := api.TraceNameResponse{Data: []api.TraceNameItem{}, User: s.userLocked(m)}
+	for _, rec := range s.records {
+		if rec.Name == req.Name {
+			res.Data = append(res.Data, api.TraceNameItem{Name: rec.Name, Mykad: rec.Mykad, ID: rec.ID})
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) traceID(m *member, body []byte) (any, error) {
+	var req api.TraceDetailRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	if err := s.aupLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.rulesLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.stepUpLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.useApprovalLocked(m, req.ApprovalID, api.ApprovalTraceDetail, req.ID, "", 0); err != nil {
+		return nil, err
+	}
+	s.countLookupLocked(m)
+
+	res := api.TraceDetailResponse{Data: []api.TraceDetailID{}, User: s.userLocked(m)}
+	for _, rec := range s.records {
+		if rec.ID == req.ID {
+			res.Data = append(res.Data, rec)
+		}
+	}
+	return minimise(res, req.Fields)
+}
+
+func (s *Server) traceNRIC(m *member, body []byte) (any, error) {
+	var req api.TraceNRICRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	if err := s.aupLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.rulesLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.stepUpLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.useApprovalLocked(m, req.ApprovalID, api.ApprovalTraceNRIC, 0, req.NRIC, 0); err != nil {
+		return nil, err
+	}
+	s.countLookupLocked(m)
+
+	res := api.TraceNRICResponse{Data: []api.TraceDetailID{}, User: s.userLocked(m)}
+	for _, rec := range s.records {
+		if rec.Mykad == req.NRIC {
+			res.Data = append(res.Data, rec)
+		}
+	}
+	return minimise(res, req.Fields)
+}
+
+func (s *Server) traceRelations(m *member, body []byte) (any, error) {
+	var req api.TraceRelationsRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+
+	if err := s.aupLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.rulesLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.stepUpLocked(m); err != nil {
+		return nil, err
+	}
+	if err := s.useApprovalLocked(m, req.ApprovalID, api.ApprovalTraceRelations, req.ID, "", req.Offset); err != nil {
+		return nil, err
+	}
+	s.countLookupLocked(m)
+
+	res := api.TraceRelationsResponse{Data: []api.TraceDetailID{}, User: s.userLocked(m)}
+	relations := s.relations[req.ID]
+	if req.Offset < 0 || req.Offset >= len(relations) {
+		return minimise(res, req.Fields)
+	}
+	res.Relationships = relations[req.Offset]
+	for _, rec := range s.records {
+		if rec.ID == res.Relationships.RelatedUserID {
+			res.Data = append(res.Data, rec)
+		}
+	}
+	return minimise(res, req.Fields)
+}
+
+// minimise drops the record fields a lookup didn't ask for from res's data.
+// The ID always stays. An empty fields asks for everything.
+func minimise(res any, fields []string) (any, error) {
+	if len(fields) == 0 {
+		return res, nil
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, err
+	}
+	records, _ := out["data"].([]any)
+	for _, rec := range records {
+		rec := rec.(map[string]any)
+		for name := range rec {
+			if name != "id" && !slices.Contains(fields, name) {
+				delete(rec, name)
+			}
+		}
+	}
+	return out, nil
+}
+
+func (s *Server) enrollTOTP(m *member, _ []byte) (any, error) {
+	if m.totpEnabled {
+		return nil, errorf(http.StatusConflict, "an authenticator app is already enrolled")
+	}
+	secret, err := totp.NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	m.totpSecret = secret
+	return api.EnrollTOTPResponse{
+		Secret: totp.EncodeSecret(secret),
+		URI:    totp.URI("Synthera", m.Email, secret),
+	}, nil
+}
+
+func (s *Server) confirmTOTP(m *member, body []byte) (any, error) {
+	if m.totpSecret == nil {
+		return nil, errorf(http.StatusConflict, "start enrollment first")
+	}
+	if err := s.checkCodeLocked(m, body); err != nil {
+		return nil, err
+	}
+	m.totpEnabled = true
+	return struct{}{}, nil
+}
+
+func (s *Server) verifyTOTP(m *member, body []byte) (any, error) {
+	if !m.totpEnabled {
+		return nil, errorf(http.StatusConflict, "no authenticator app is enrolled, run synthera totp enroll")
+	}
+	if err := s.checkCodeLocked(m, body); err != nil {
+		return nil, err
+	}
+	grace := time.Duration(s.policy.StepUpGraceSeconds) * time.Second
+	if grace <= 0 {
+		grace = defaultStepUpGrace
+	}
+	m.stepUpUntil = s.Now().Add(grace)
+	return api.StepUpResponse{GraceSeconds: int(grace / time.Second)}, nil
+}
+
+// checkCodeLocked accepts a code from the step before or after the current
+// one, but never the same step twice.
+func (s *Server) checkCodeLocked(m *member, body []byte) error {
+	var req api.TOTPCodeRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return err
+	}
+	step, ok := totp.Verify(m.totpSecret, req.Code, s.Now(), 1)
+	if !ok || step <= m.totpStep {
+		return errorf(http.StatusUnprocessableEntity, "that code is not valid")
+	}
+	m.totpStep = step
+	return nil
+}
+
+// aupLocked refuses trace calls until the member has accepted the current
+// acceptable-use policy, when one is published.
+func (s *Server) aupLocked(m *member) error {
+	if s.aup == nil || m.aupVersion == s.aup.Version {
+		return nil
+	}
+	return errorf(http.StatusForbidden, "accept the acceptable-use policy, version %s, before tracing", s.aup.Version)
+}
+
+// rulesLocked refuses a lookup the usage rules don't allow, as the real
+// backend does whatever the client checked first.
+func (s *Server) rulesLocked(m *member) error {
+	if s.rules == nil {
+		return nil
+	}
+	now := s.Now()
+	m.lookups = slices.DeleteFunc(m.lookups, func(t time.Time) bool { return now.Sub(t) >= time.Hour })
+	v := rules.Check(*s.rules, now, len(m.lookups))
+	switch {
+	case v == nil:
+		return nil
+	case v.Rule == rules.RuleRate:
+		return errorf(http.StatusTooManyRequests, "%s", v.Error())
+	}
+	return errorf(http.StatusForbidden, "%s", v.Error())
+}
+
+// countLookupLocked records a lookup that went ahead against the rate rule.
+func (s *Server) countLookupLocked(m *member) {
+	m.lookups = append(m.lookups, s.Now())
+}
+
+// stepUpLocked refuses detail lookups outside a grace window for members
+// who enrolled, or for everyone when the policy requires it.
+func (s *Server) stepUpLocked(m *member) error {
+	switch {
+	case !m.totpEnabled && !s.policy.StepUpRequired:
+		return nil
+	case !m.totpEnabled:
+		return errorf(http.StatusPreconditionRequired, "this lookup needs two-step verification, run synthera totp enroll")
+	case s.Now().After(m.stepUpUntil):
+		return errorf(http.StatusPreconditionRequired, "enter a one-time code to continue")
+	}
+	return nil
+}
+
+func (s *Server) submitApproval(m *member, body []byte) (any, error) {
+	var req api.SubmitApprovalRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	switch {
+	case req.Justification == "":
+		return nil, errorf(http.StatusBadRequest, "a justification is required")
+	case req.Kind == api.ApprovalTraceDetail && req.RecordID == 0,
+		req.Kind == api.ApprovalTraceRelations && req.RecordID == 0,
+		req.Kind == api.ApprovalTraceNRIC && req.NRIC == "":
+		return nil, errorf(http.StatusBadRequest, "nothing to look up")
+	case req.Kind != api.ApprovalTraceDetail && req.Kind != api.ApprovalTraceNRIC && req.Kind != api.ApprovalTraceRelations:
+		return nil, errorf(http.StatusBadRequest, "unknown lookup %q", req.Kind)
+	}
+
+	a := &approval{
+		Approval: api.Approval{
+			ID:            len(s.approvals) + 1,
+			Kind:          req.Kind,
+			RecordID:      req.RecordID,
+			NRIC:          req.NRIC,
+			Offset:        req.Offset,
+			Justification: req.Justification,
+			Status:        api.ApprovalPending,
+			RequestedBy:   m.Name,
+			CreatedAt:     s.Now().UTC(),
+		},
+		requesterID: m.ID,
+	}
+	s.approvals = append(s.approvals, a)
+	return api.ApprovalResponse{Data: a.Approval}, nil
+}
+
+func (s *Server) myApprovals(m *member, _ []byte) (any, error) {
+	res := api.ApprovalsResponse{Data: []api.Approval{}}
+	for i := len(s.approvals) - 1; i >= 0; i-- {
+		if a := s.approvals[i]; a.requesterID == m.ID {
+			res.Data = append(res.Data, a.Approval)
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) pendingApprovals(m *member, _ []byte) (any, error) {
+	res := api.ApprovalsResponse{Data: []api.Approval{}}
+	for _, a := range s.approvals {
+		if a.Status == api.ApprovalPending && a.requesterID != m.ID {
+			res.Data = append(res.Data, a.Approval)
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) decideApproval(m *member, body []byte) (any, error) {
+	var req api.DecideApprovalRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.ID < 1 || req.ID > len(s.approvals) {
+		return nil, errorf(http.StatusNotFound, "approval %d not found", req.ID)
+	}
+	a := s.approvals[req.ID-1]
+	switch {
+	case a.requesterID == m.ID:
+		return nil, errorf(http.StatusForbidden, "you cannot decide your own request")
+	case a.Status != api.ApprovalPending:
+		return nil, errorf(http.StatusConflict, "approval %d is already %s", a.ID, a.Status)
+	}
+
+	a.Status = api.ApprovalRejected
+	if req.Approve {
+		a.Status = api.ApprovalApproved
+	}
+	a.DecidedBy = m.Name
+	a.DecidedAt = s.Now().UTC()
+	return api.ApprovalResponse{Data: a.Approval}, nil
+}
+
+// useApprovalLocked checks that a lookup is covered by an approved request
+// from the same member when the policy requires one, and uses it up.
+func (s *Server) useApprovalLocked(m *member, id int, kind string, recordID int, nric string, offset int) error {
+	if !s.policy.ApprovalsRequired {
+		return nil
+	}
+	if id < 1 || id > len(s.approvals) {
+		return errorf(http.StatusForbidden, "this lookup needs an approved request")
+	}
+	a := s.approvals[id-1]
+	switch {
+	case a.requesterID != m.ID || a.Kind != kind || a.RecordID != recordID || a.NRIC != nric || a.Offset != offset:
+		return errorf(http.StatusForbidden, "approval %d does not cover this lookup", id)
+	case a.Status != api.ApprovalApproved:
+		return errorf(http.StatusForbidden, "approval %d is %s", id, a.Status)
+	}
+	a.Status = api.ApprovalUsed
+	return nil
+}
+
+func (s *Server) submitReport(m *member, body []byte) (any, error) {
+	var req api.SubmitReportRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(req.Fields) == 0:
+		return nil, errorf(http.StatusBadRequest, "choose at least one field")
+	case req.Comment == "":
+		return nil, errorf(http.StatusBadRequest, "a comment is required")
+	}
+	for _, f := range req.Fields {
+		if !slices.Contains(api.RecordFields(), f) {
+			return nil, errorf(http.StatusBadRequest, "unknown field %q", f)
+		}
+	}
+	if !slices.ContainsFunc(s.records, func(r api.TraceDetailID) bool { return r.ID == req.RecordID }) {
+		return nil, errorf(http.StatusNotFound, "record %d not found", req.RecordID)
+	}
+
+	now := s.Now().UTC()
+	r := &report{
+		Report: api.Report{
+			ID:        len(s.reports) + 1,
+			RecordID:  req.RecordID,
+			Fields:    req.Fields,
+			Comment:   req.Comment,
+			Status:    api.ReportOpen,
+			CreatedAt: now,
+			UpdatedAt: now,
+		},
+		reporterID: m.ID,
+	}
+	s.reports = append(s.reports, r)
+	return api.ReportResponse{Data: r.Report}, nil
+}
+
+func (s *Server) myReports(m *member, _ []byte) (any, error) {
+	res := api.ReportsResponse{Data: []api.Report{}}
+	for i := len(s.reports) - 1; i >= 0; i-- {
+		if r := s.reports[i]; r.reporterID == m.ID {
+			res.Data = append(res.Data, r.Report)
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) teamMembers(_ *member, _ []byte) (any, error) {
+	res := api.TeamMembersResponse{Data: []api.TeamMember{}}
+	for id := 1; id < s.nextID; id++ {
+		if m, ok := s.members[id]; ok {
+			res.Data = append(res.Data, m.TeamMember)
+		}
+	}
+	return res, nil
+}
+
+func (s *Server) issueToken(caller *member, body []byte) (any, error) {
+	var req api.TeamTokenRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if _, err := s.manageableLocked(caller, req.MemberID); err != nil {
+		return nil, err
+	}
+
+	token, info := s.newTokenLocked(req.MemberID)
+	return api.IssueTokenResponse{Token: token, Info: info}, nil
+}
+
+func (s *Server) revokeToken(caller *member, body []byte) (any, error) {
+	var req api.TeamTokenRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	target, err := s.manageableLocked(caller, req.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.revokeLocked(target, req.TokenID); err != nil {
+		return nil, err
+	}
+	return api.TeamMemberResponse{Data: target.TeamMember}, nil
+}
+
+func (s *Server) revokeLocked(m *member, tokenID int) error {
+	for i, info := range m.Tokens {
+		if info.ID != tokenID {
+			continue
+		}
+		m.Tokens = append(m.Tokens[:i], m.Tokens[i+1:]...)
+		for token, ref := range s.tokens {
+			if ref.tokenID == tokenID {
+				delete(s.tokens, token)
+				delete(s.secrets, token)
+			}
+		}
+		return nil
+	}
+	return errorf(http.StatusNotFound, "token %d not found", tokenID)
+}
+
+func (s *Server) setLimit(caller *member, body []byte) (any, error) {
+	var req api.TeamLimitRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.SpendLimit < 0 {
+		return nil, errorf(http.StatusBadRequest, "spend limit cannot be negative")
+	}
+	target, err := s.manageableLocked(caller, req.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == caller.ID && caller.Role != api.RoleOwner {
+		return nil, errorf(http.StatusForbidden, "only the owner can change an admin's limit")
+	}
+	target.SpendLimit = req.SpendLimit
+	return api.TeamMemberResponse{Data: target.TeamMember}, nil
+}
+
+func (s *Server) suspend(caller *member, body []byte) (any, error) {
+	var req api.TeamSuspendRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	target, err := s.manageableLocked(caller, req.MemberID)
+	if err != nil {
+		return nil, err
+	}
+	if target.ID == caller.ID {
+		return nil, errorf(http.StatusBadRequest, "cannot suspend yourself")
+	}
+	target.Suspended = req.Suspended
+	return api.TeamMemberResponse{Data: target.TeamMember}, nil
+}
+
+func (s *Server) listTokens(m *member, _ []byte) (any, error) {
+	res := api.TokensResponse{Data: []api.TokenInfo{}}
+	for _, info := range m.Tokens {
+		info.Current = info.ID == s.caller.tokenID
+		res.Data = append(res.Data, info)
+	}
+	return res, nil
+}
+
+// rotateToken replaces the caller's token. A token that had to be signed
+// gets a fresh secret with its replacement, so rotating doesn't quietly
+// turn signing off.
+func (s *Server) rotateToken(m *member, _ []byte) (any, error) {
+	old := s.caller.tokenID
+	signed := false
+	for token, ref := range s.tokens {
+		if ref.tokenID == old {
+			_, signed = s.secrets[token]
+		}
+	}
+
+	token, info := s.newTokenLocked(m.ID)
+	if err := s.revokeLocked(m, old); err != nil {
+		return nil, err
+	}
+	info.Current = true
+	res := api.IssueTokenResponse{Token: token, Info: info}
+	if signed {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		s.secrets[token] = secret
+		res.SigningSecret = hex.EncodeToString(secret)
+	}
+	return res, nil
+}
+
+func (s *Server) revokeOwnToken(m *member, body []byte) (any, error) {
+	var req api.RevokeTokenRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	if req.Current {
+		req.TokenID = s.caller.tokenID
+	}
+	if err := s.revokeLocked(m, req.TokenID); err != nil {
+		return nil, err
+	}
+	return struct{}{}, nil
+}
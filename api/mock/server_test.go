@@ -0,0 +1,506 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"synthera/api"
+	"synthera/totp"
+	"testing"
+	"time"
+)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s := NewServer()
+	t.Cleanup(s.Close)
+	return s
+}
+
+func wantStatus(t *testing.T, err error, code int) {
+	t.Helper()
+	var statusErr *api.StatusError
+	if !errors.As(err, &statusErr) || statusErr.Code != code {
+		t.Fatalf("err = %v, want status %d", err, code)
+	}
+}
+
+// captured keeps the last request sent through it, body included, so a
+// test can send it again as an attacker who recorded it would.
+type captured struct {
+	req  *http.Request
+	body []byte
+}
+
+func (c *captured) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.req, c.body = req, body
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// replay sends the captured request again, with body in place of its own.
+func (c *captured) replay(t *testing.T, body []byte) int {
+	t.Helper()
+	req := c.req.Clone(context.Background())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	resp, err := http.DefaultTransport.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+func TestSignedRequests(t *testing.T) {
+	s := newTestServer(t)
+	clock := time.Now()
+	s.Now = func() time.Time { return clock }
+	token := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+	secret := s.RequireSigning(token)
+	s.AddRecord(api.TraceDetailID{ID: 7, Name: "ALI"})
+	s.AddRecord(api.TraceDetailID{ID: 8, Name: "SITI"})
+
+	_, err := api.NewClient(token, api.WithBaseURL(s.URL)).Account()
+	wantStatus(t, err, http.StatusUnauthorized)
+	_, err = api.NewClient(token, api.WithBaseURL(s.URL), api.WithSigningSecret([]byte("other"))).Account()
+	wantStatus(t, err, http.StatusUnauthorized)
+
+	var last captured
+	c := api.NewClient(token, api.WithBaseURL(s.URL), api.WithSigningSecret(secret), api.WithHTTPTransport(&last))
+	if _, err := c.TraceDetail(7); err != nil {
+		t.Fatalf("signed lookup: %v", err)
+	}
+	if code := last.replay(t, last.body); code != http.StatusUnauthorized {
+		t.Errorf("replayed request = %d, want 401", code)
+	}
+
+	if _, err := c.TraceDetail(7); err != nil {
+		t.Fatal(err)
+	}
+	tampered := bytes.Replace(last.body, []byte("7"), []byte("8"), 1)
+	if code := last.replay(t, tampered); code != http.StatusUnauthorized {
+		t.Errorf("request with a changed body = %d, want 401", code)
+	}
+
+	if _, err := c.TraceDetail(7); err != nil {
+		t.Fatal(err)
+	}
+	clock = clock.Add(api.DefaultMaxSkew + time.Minute)
+	if code := last.replay(t, last.body); code != http.StatusUnauthorized {
+		t.Errorf("request replayed after the skew window = %d, want 401", code)
+	}
+}
+
+func TestRotateTokenKeepsSigning(t *testing.T) {
+	s := newTestServer(t)
+	token := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+	secret := s.RequireSigning(token)
+	c := api.NewClient(token, api.WithBaseURL(s.URL), api.WithSigningSecret(secret))
+
+	rotated, newSecret, err := c.RotateToken()
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if newSecret == "" {
+		t.Fatal("rotating a signed token returned no signing secret")
+	}
+	raw, err := hex.DecodeString(newSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := api.NewClient(rotated, api.WithBaseURL(s.URL)).Account(); err == nil {
+		t.Error("unsigned request with the rotated token was accepted")
+	}
+	if _, err := api.NewClient(rotated, api.WithBaseURL(s.URL), api.WithSigningSecret(secret)).Account(); err == nil {
+		t.Error("request signed with the old secret was accepted")
+	}
+	if _, err := api.NewClient(rotated, api.WithBaseURL(s.URL), api.WithSigningSecret(raw)).Account(); err != nil {
+		t.Errorf("request signed with the new secret: %v", err)
+	}
+	_, err = c.Account()
+	wantStatus(t, err, http.StatusUnauthorized)
+}
+
+func TestRotateTokenUnsigned(t *testing.T) {
+	s := newTestServer(t)
+	c := api.NewClient(s.AddMember("Op", "op@example.com", api.RoleMember, 10), api.WithBaseURL(s.URL))
+
+	rotated, secret, err := c.RotateToken()
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if secret != "" {
+		t.Errorf("unsigned token rotated with secret %q", secret)
+	}
+	if _, err := api.NewClient(rotated, api.WithBaseURL(s.URL)).Account(); err != nil {
+		t.Errorf("rotated token: %v", err)
+	}
+}
+
+func TestApprovals(t *testing.T) {
+	s := newTestServer(t)
+	op := api.NewClient(s.AddMember("Op", "op@example.com", api.RoleMember, 10), api.WithBaseURL(s.URL))
+	sup := api.NewClient(s.AddMember("Sup", "sup@example.com", api.RoleAdmin, 10), api.WithBaseURL(s.URL))
+	s.AddRecord(api.TraceDetailID{ID: 7, Name: "ALI", Mykad: "800101015555"})
+	s.AddRecord(api.TraceDetailID{ID: 8, Name: "SITI", Mykad: "850505105555"})
+	s.AddRelation(7, 8, "Spouse")
+	s.SetPolicy(api.Policy{ApprovalsRequired: true})
+
+	submit := func(t *testing.T, req api.SubmitApprovalRequest) api.Approval {
+		t.Helper()
+		req.Justification = "case 42"
+		a, err := op.SubmitApproval(req)
+		if err != nil {
+			t.Fatalf("SubmitApproval: %v", err)
+		}
+		return a
+	}
+	decide := func(t *testing.T, a api.Approval, approve bool) {
+		t.Helper()
+		if _, err := sup.DecideApproval(a.ID, approve); err != nil {
+			t.Fatalf("DecideApproval: %v", err)
+		}
+	}
+
+	t.Run("lookup needs an approval", func(t *testing.T) {
+		_, err := op.TraceDetail(7)
+		wantStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("self-approval refused", func(t *testing.T) {
+		a, err := sup.SubmitApproval(api.SubmitApprovalRequest{Kind: api.ApprovalTraceDetail, RecordID: 7, Justification: "mine"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = sup.DecideApproval(a.ID, true)
+		wantStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("single use", func(t *testing.T) {
+		a := submit(t, api.SubmitApprovalRequest{Kind: api.ApprovalTraceDetail, RecordID: 7})
+		_, err := op.TraceDetailApproved(7, a.ID)
+		wantStatus(t, err, http.StatusForbidden)
+
+		decide(t, a, true)
+		details, err := op.TraceDetailApproved(7, a.ID)
+		if err != nil || len(details) != 1 || details[0].ID != 7 {
+			t.Fatalf("approved lookup = %v, %v", details, err)
+		}
+		_, err = op.TraceDetailApproved(7, a.ID)
+		wantStatus(t, err, http.StatusForbidden)
+
+		mine, err := op.MyApprovals()
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range mine {
+			if m.ID == a.ID && m.Status != api.ApprovalUsed {
+				t.Errorf("status after use = %s, want %s", m.Status, api.ApprovalUsed)
+			}
+		}
+	})
+
+	t.Run("covers only its own lookup", func(t *testing.T) {
+		a := submit(t, api.SubmitApprovalRequest{Kind: api.ApprovalTraceDetail, RecordID: 7})
+		decide(t, a, true)
+		_, err := op.TraceDetailApproved(8, a.ID)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = op.TraceNRICApproved("800101015555", a.ID)
+		wantStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		a := submit(t, api.SubmitApprovalRequest{Kind: api.ApprovalTraceNRIC, NRIC: "800101015555"})
+		decide(t, a, false)
+		_, err := op.TraceNRICApproved("800101015555", a.ID)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = sup.DecideApproval(a.ID, true)
+		wantStatus(t, err, http.StatusConflict)
+	})
+
+	t.Run("relationship pages", func(t *testing.T) {
+		a := submit(t, api.SubmitApprovalRequest{Kind: api.ApprovalTraceRelations, RecordID: 7, Offset: 0})
+		decide(t, a, true)
+		_, _, err := op.TraceRelationsApproved(7, 1, a.ID)
+		wantStatus(t, err, http.StatusForbidden)
+		_, relations, err := op.TraceRelationsApproved(7, 0, a.ID)
+		if err != nil {
+			t.Fatalf("approved page: %v", err)
+		}
+		if relations.RelatedUserID != 8 {
+			t.Errorf("relation = %+v, want record 8", relations)
+		}
+	})
+}
+
+func TestStepUp(t *testing.T) {
+	s := newTestServer(t)
+	clock := time.Now()
+	s.Now = func() time.Time { return clock }
+	op := api.NewClient(s.AddMember("Op", "op@example.com", api.RoleMember, 10), api.WithBaseURL(s.URL))
+	s.AddRecord(api.TraceDetailID{ID: 7, Name: "ALI", Mykad: "800101015555"})
+	s.SetPolicy(api.Policy{StepUpGraceSeconds: 60})
+
+	if _, err := op.TraceDetail(7); err != nil {
+		t.Fatalf("lookup before enrolling: %v", err)
+	}
+
+	encoded, _, err := op.EnrollTOTP()
+	if err != nil {
+		t.Fatalf("EnrollTOTP: %v", err)
+	}
+	secret, err := totp.DecodeSecret(encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	confirmed := totp.Code(secret, clock)
+	wrong := "000000"
+	if confirmed == wrong {
+		wrong = "111111"
+	}
+	if err := op.ConfirmTOTP(wrong); !api.IsInvalidCode(err) {
+		t.Errorf("confirming with a wrong code = %v, want invalid", err)
+	}
+	if err := op.ConfirmTOTP(confirmed); err != nil {
+		t.Fatalf("ConfirmTOTP: %v", err)
+	}
+
+	_, err = op.TraceDetail(7)
+	if !api.IsStepUpRequired(err) {
+		t.Fatalf("lookup after enrolling = %v, want step-up required", err)
+	}
+
+	if _, err := op.VerifyStepUp(confirmed); !api.IsInvalidCode(err) {
+		t.Errorf("replayed code = %v, want invalid", err)
+	}
+	if _, err := op.VerifyStepUp(wrong); !api.IsInvalidCode(err) {
+		t.Errorf("wrong code = %v, want invalid", err)
+	}
+
+	clock = clock.Add(totp.Period)
+	grace, err := op.VerifyStepUp(totp.Code(secret, clock))
+	if err != nil {
+		t.Fatalf("VerifyStepUp: %v", err)
+	}
+	if grace != time.Minute {
+		t.Errorf("grace = %v, want the policy's minute", grace)
+	}
+	if _, err := op.TraceDetail(7); err != nil {
+		t.Errorf("lookup within grace: %v", err)
+	}
+
+	clock = clock.Add(time.Minute + time.Second)
+	if _, err := op.TraceDetail(7); !api.IsStepUpRequired(err) {
+		t.Errorf("lookup after grace = %v, want step-up required", err)
+	}
+}
+
+func TestTeam(t *testing.T) {
+	s := newTestServer(t)
+	join := func(name, role string) (*api.Client, int) {
+		t.Helper()
+		c := api.NewClient(s.AddMember(name, name+"@example.com", role, 10), api.WithBaseURL(s.URL))
+		user, err := c.Account()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c, user.ID
+	}
+	owner, ownerID := join("Owner", api.RoleOwner)
+	admin, adminID := join("Admin", api.RoleAdmin)
+	_, otherAdminID := join("Other", api.RoleAdmin)
+	op, opID := join("Op", api.RoleMember)
+
+	t.Run("members can't manage", func(t *testing.T) {
+		_, err := op.TeamMembers()
+		wantStatus(t, err, http.StatusForbidden)
+		_, _, err = op.IssueMemberToken(opID)
+		wantStatus(t, err, http.StatusForbidden)
+	})
+
+	t.Run("issue and revoke", func(t *testing.T) {
+		token, info, err := admin.IssueMemberToken(opID)
+		if err != nil {
+			t.Fatalf("IssueMemberToken: %v", err)
+		}
+		issued := api.NewClient(token, api.WithBaseURL(s.URL))
+		if user, err := issued.Account(); err != nil || user.ID != opID {
+			t.Fatalf("issued token = %+v, %v, want member %d", user, err, opID)
+		}
+		if _, err := admin.RevokeMemberToken(opID, info.ID); err != nil {
+			t.Fatalf("RevokeMemberToken: %v", err)
+		}
+		_, err = issued.Account()
+		wantStatus(t, err, http.StatusUnauthorized)
+		_, err = admin.RevokeMemberToken(opID, info.ID)
+		wantStatus(t, err, http.StatusNotFound)
+	})
+
+	t.Run("rank", func(t *testing.T) {
+		members, err := owner.TeamMembers()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ownerToken int
+		for _, m := range members {
+			if m.ID == ownerID {
+				ownerToken = m.Tokens[0].ID
+			}
+		}
+
+		_, _, err = admin.IssueMemberToken(ownerID)
+		wantStatus(t, err, http.StatusForbidden)
+		_, _, err = admin.IssueMemberToken(otherAdminID)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = admin.RevokeMemberToken(ownerID, ownerToken)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = admin.SetMemberLimit(otherAdminID, 1)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = admin.SetMemberLimit(adminID, 0)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = admin.SuspendMember(otherAdminID, true)
+		wantStatus(t, err, http.StatusForbidden)
+		_, err = admin.SuspendMember(ownerID, true)
+		wantStatus(t, err, http.StatusForbidden)
+
+		if _, _, err := owner.IssueMemberToken(adminID); err != nil {
+			t.Errorf("owner issuing an admin a token: %v", err)
+		}
+		if _, err := owner.SetMemberLimit(adminID, 50); err != nil {
+			t.Errorf("owner limiting an admin: %v", err)
+		}
+	})
+
+	t.Run("limit", func(t *testing.T) {
+		member, err := admin.SetMemberLimit(opID, 5)
+		if err != nil || member.SpendLimit != 5 {
+			t.Fatalf("SetMemberLimit = %+v, %v", member, err)
+		}
+		_, err = admin.SetMemberLimit(opID, -1)
+		wantStatus(t, err, http.StatusBadRequest)
+		if member, err := admin.SetMemberLimit(opID, 0); err != nil || member.SpendLimit != 0 {
+			t.Errorf("lifting the limit = %+v, %v", member, err)
+		}
+	})
+
+	t.Run("suspend", func(t *testing.T) {
+		_, err := owner.SuspendMember(ownerID, true)
+		wantStatus(t, err, http.StatusBadRequest)
+
+		if _, err := admin.SuspendMember(opID, true); err != nil {
+			t.Fatalf("SuspendMember: %v", err)
+		}
+		_, err = op.Account()
+		wantStatus(t, err, http.StatusForbidden)
+		if _, err := admin.SuspendMember(opID, false); err != nil {
+			t.Fatalf("resuming: %v", err)
+		}
+		if _, err := op.Account(); err != nil {
+			t.Errorf("Account after resuming: %v", err)
+		}
+
+		if _, err := owner.SuspendMember(adminID, true); err != nil {
+			t.Fatalf("owner suspending an admin: %v", err)
+		}
+		_, err = admin.TeamMembers()
+		wantStatus(t, err, http.StatusForbidden)
+	})
+}
+
+func TestAUP(t *testing.T) {
+	s := newTestServer(t)
+	c := api.NewClient(s.AddMember("Op", "op@example.com", api.RoleMember, 10), api.WithBaseURL(s.URL))
+	s.AddRecord(api.TraceDetailID{ID: 7, Name: "ALI"})
+
+	_, err := c.AcceptAUP("1")
+	wantStatus(t, err, http.StatusNotFound)
+	if _, err := c.TraceDetail(7); err != nil {
+		t.Fatalf("lookup with no policy to accept: %v", err)
+	}
+
+	s.SetAUP("1", "Look up only what a case needs.")
+	_, err = c.TraceDetail(7)
+	wantStatus(t, err, http.StatusForbidden)
+	_, err = c.TraceName("ALI", 1)
+	wantStatus(t, err, http.StatusForbidden)
+	_, err = c.AcceptAUP("0")
+	wantStatus(t, err, http.StatusConflict)
+
+	if _, err := c.AcceptAUP("1"); err != nil {
+		t.Fatalf("AcceptAUP: %v", err)
+	}
+	if aup, err := c.AUP(); err != nil || aup.AcceptedVersion != "1" {
+		t.Fatalf("AUP = %+v, %v, want version 1 accepted", aup, err)
+	}
+	if _, err := c.TraceDetail(7); err != nil {
+		t.Fatalf("lookup after accepting: %v", err)
+	}
+
+	s.SetAUP("2", "Look up only what a case needs, and log why.")
+	_, err = c.TraceDetail(7)
+	wantStatus(t, err, http.StatusForbidden)
+	if aup, err := c.AUP(); err != nil || aup.Version != "2" || aup.AcceptedVersion != "1" {
+		t.Errorf("AUP after the bump = %+v, %v, want version 2 with 1 accepted", aup, err)
+	}
+	if _, err := c.AcceptAUP("2"); err != nil {
+		t.Fatalf("accepting version 2: %v", err)
+	}
+	if _, err := c.TraceDetail(7); err != nil {
+		t.Errorf("lookup after accepting version 2: %v", err)
+	}
+}
+
+func TestReports(t *testing.T) {
+	s := newTestServer(t)
+	op := api.NewClient(s.AddMember("Op", "op@example.com", api.RoleMember, 10), api.WithBaseURL(s.URL))
+	other := api.NewClient(s.AddMember("Other", "other@example.com", api.RoleMember, 10), api.WithBaseURL(s.URL))
+	s.AddRecord(api.TraceDetailID{ID: 7, Name: "ALI"})
+
+	for _, tt := range []struct {
+		name string
+		req  api.SubmitReportRequest
+		want int
+	}{
+		{"no comment", api.SubmitReportRequest{RecordID: 7, Fields: []string{"address"}}, http.StatusBadRequest},
+		{"no fields", api.SubmitReportRequest{RecordID: 7, Comment: "moved"}, http.StatusBadRequest},
+		{"unknown field", api.SubmitReportRequest{RecordID: 7, Fields: []string{"salary"}, Comment: "moved"}, http.StatusBadRequest},
+		{"unknown record", api.SubmitReportRequest{RecordID: 8, Fields: []string{"address"}, Comment: "moved"}, http.StatusNotFound},
+	} {
+		_, err := op.SubmitReport(tt.req)
+		t.Run(tt.name, func(t *testing.T) { wantStatus(t, err, tt.want) })
+	}
+
+	sent, err := op.SubmitReport(api.SubmitReportRequest{RecordID: 7, Fields: []string{"address", "city"}, Comment: "moved"})
+	if err != nil {
+		t.Fatalf("SubmitReport: %v", err)
+	}
+	reports, err := op.MyReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 {
+		t.Fatalf("MyReports returned %d reports, want only the accepted one", len(reports))
+	}
+	got := reports[0]
+	if got.ID != sent.ID || got.RecordID != 7 || got.Comment != "moved" || got.Status != api.ReportOpen ||
+		len(got.Fields) != 2 || got.Fields[0] != "address" || got.Fields[1] != "city" {
+		t.Errorf("stored report = %+v, want record 7, address and city, \"moved\", open", got)
+	}
+	if theirs, err := other.MyReports(); err != nil || len(theirs) != 0 {
+		t.Errorf("another member's reports = %+v, %v, want none", theirs, err)
+	}
+
+	s.ResolveReport(sent.ID, api.ReportCorrected, "address updated")
+	if reports, err := op.MyReports(); err != nil || reports[0].Status != api.ReportCorrected || reports[0].Resolution != "address updated" {
+		t.Errorf("resolved report = %+v, %v", reports, err)
+	}
+}
@@ -0,0 +1,142 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+
+	"synthera/version"
+)
+
+// APIVersion is the backend contract this client was built against. It is
+// sent as Accept-Version; a backend that has moved on answers 426.
+const APIVersion = "1"
+
+var ErrIncompatibleVersion = errors.New("this version of synthera is no longer supported by the backend")
+
+// SchemaError reports a response missing fields tagged `required:"true"`
+// in types.go. Fields the client doesn't know about are not an error, so a
+// backend can add to a response without locking older clients out.
+type SchemaError struct {
+	Endpoint string
+	Missing  []string
+}
+
+func (e *SchemaError) Error() string {
+	return fmt.Sprintf("response from %s does not match the expected schema: missing %s", e.Endpoint, strings.Join(e.Missing, ", "))
+}
+
+// IsIncompatible reports whether err means the CLI needs updating, either
+// because the backend said so or because its responses lack fields this
+// build depends on.
+func IsIncompatible(err error) bool {
+	var schemaErr *SchemaError
+	return errors.Is(err, ErrIncompatibleVersion) || errors.As(err, &schemaErr)
+}
+
+func userAgent() string {
+	return fmt.Sprintf("synthera-cli/%s (%s/%s; api %s)", version.Version, runtime.GOOS, runtime.GOARCH, APIVersion)
+}
+
+// decodeStrict checks data against v's schema before decoding it, and
+// returns the fields in data that v has no place for. Record fields in
+// hidden may be missing even if required, and are dropped if present, so
+// the decoded value never holds them.
+func decodeStrict(endpoint string, data []byte, v any, hidden map[string]bool) (unknown []string, err error) {
+	var raw any
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+
+	s := &schemaCheck{hidden: hidden}
+	s.check(reflect.TypeOf(v), raw, "")
+	sort.Strings(s.unknown)
+	if len(s.missing) > 0 {
+		sort.Strings(s.missing)
+		return s.unknown, &SchemaError{Endpoint: endpoint, Missing: s.missing}
+	}
+	if len(hidden) > 0 {
+		// Decode what is left after check pruned the hidden fields.
+		pruned, err := json.Marshal(raw)
+		if err != nil {
+			return s.unknown, err
+		}
+		data = pruned
+	}
+	return s.unknown, json.Unmarshal(data, v)
+}
+
+// schemaCheck collects the differences between a response and its type.
+type schemaCheck struct {
+	hidden  map[string]bool
+	missing []string
+	unknown []string
+}
+
+func (s *schemaCheck) check(t reflect.Type, raw any, path string) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Slice, reflect.Array:
+		items, ok := raw.([]any)
+		if !ok {
+			return
+		}
+		for i, item := range items {
+			s.check(t.Elem(), item, fmt.Sprintf("%s[%d]", path, i))
+		}
+	case reflect.Struct:
+		obj, ok := raw.(map[string]any)
+		if !ok || t.PkgPath() == "time" {
+			return
+		}
+
+		known := map[string]bool{}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if !f.IsExported() || name == "-" {
+				continue
+			}
+			if name == "" {
+				name = f.Name
+			}
+			known[name] = true
+
+			if t == recordType && s.hidden[name] {
+				delete(obj, name)
+				continue
+			}
+			value, present := obj[name]
+			if !present {
+				if f.Tag.Get("required") == "true" {
+					s.missing = append(s.missing, join(path, name))
+				}
+				continue
+			}
+			s.check(f.Type, value, join(path, name))
+		}
+
+		for name := range obj {
+			if !known[name] {
+				s.unknown = append(s.unknown, join(path, name))
+			}
+		}
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
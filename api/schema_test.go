@@ -0,0 +1,90 @@
+package api
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+const fullRecord = `{"id":7,"name":"ALI","mykad":"800101015555","address":"1 JALAN","city":"KL",` +
+	`"postcode":"50000","state":"WP","phone":"03","gender":"M","mobile":"012","race":"X",` +
+	`"religion":"Y","income":"1","occupations":"Z","addresses":"A"}`
+
+func TestDecodeStrict(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantMissing []string
+		wantUnknown []string
+	}{
+		{
+			name: "exact",
+			data: `{"data":[{"name":"ALI","mykad":"800101015555","id":7}]}`,
+		},
+		{
+			name:        "unknown fields are reported, not rejected",
+			data:        `{"data":[{"name":"ALI","mykad":"800101015555","id":7,"nick":"A"}],"next":2}`,
+			wantUnknown: []string{"data[0].nick", "next"},
+		},
+		{
+			name:        "missing required field",
+			data:        `{"data":[{"name":"ALI","id":7}]}`,
+			wantMissing: []string{"data[0].mykad"},
+		},
+		{
+			name:        "missing top-level field",
+			data:        `{"message":"ok"}`,
+			wantMissing: []string{"data"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var res TraceNameResponse
+			unknown, err := decodeStrict("/trace/name", []byte(tt.data), &res, nil)
+			if !reflect.DeepEqual(unknown, tt.wantUnknown) {
+				t.Errorf("unknown = %v, want %v", unknown, tt.wantUnknown)
+			}
+			var schemaErr *SchemaError
+			if tt.wantMissing == nil {
+				if err != nil {
+					t.Fatalf("decodeStrict: %v", err)
+				}
+				if len(res.Data) != 1 || res.Data[0].Mykad != "800101015555" {
+					t.Errorf("decoded %+v", res)
+				}
+				return
+			}
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("err = %v, want a SchemaError", err)
+			}
+			if !reflect.DeepEqual(schemaErr.Missing, tt.wantMissing) {
+				t.Errorf("missing = %v, want %v", schemaErr.Missing, tt.wantMissing)
+			}
+			if !IsIncompatible(err) {
+				t.Error("IsIncompatible = false for a missing required field")
+			}
+		})
+	}
+}
+
+func TestDecodeStrictHidden(t *testing.T) {
+	hidden := map[string]bool{"mykad": true}
+
+	var res TraceDetailResponse
+	data := `{"data":[` + fullRecord + `]}`
+	if _, err := decodeStrict("/trace/id", []byte(data), &res, hidden); err != nil {
+		t.Fatalf("decodeStrict: %v", err)
+	}
+	if len(res.Data) != 1 || res.Data[0].Mykad != "" || res.Data[0].Name != "ALI" {
+		t.Errorf("hidden field kept: %+v", res.Data)
+	}
+
+	// A hidden field may be left out even though it is required.
+	res = TraceDetailResponse{}
+	data = `{"data":[{"id":7,"name":"ALI","address":"1 JALAN","city":"KL","postcode":"50000",` +
+		`"state":"WP","phone":"03","gender":"M","mobile":"012","race":"X","religion":"Y",` +
+		`"income":"1","occupations":"Z","addresses":"A"}]}`
+	if _, err := decodeStrict("/trace/id", []byte(data), &res, hidden); err != nil {
+		t.Fatalf("decodeStrict without hidden field: %v", err)
+	}
+}
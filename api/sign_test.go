@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	secret := []byte("secret")
+	body := []byte(`{"id":7}`)
+	now := time.Now()
+
+	signed := func(t *testing.T) *http.Request {
+		t.Helper()
+		req, err := http.NewRequest("POST", "https://example.com/trace/detail", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := (&signer{secret: secret}).sign(req, body); err != nil {
+			t.Fatal(err)
+		}
+		return req
+	}
+	// resign signs req again after a change, as a client whose clock is
+	// off would, so only the timestamp check can catch it.
+	resign := func(req *http.Request) {
+		req.Header.Set(HeaderSignature, signature(secret, req, body))
+	}
+
+	tests := []struct {
+		name   string
+		change func(req *http.Request) (secret, body []byte)
+		want   error
+	}{
+		{"valid", func(*http.Request) ([]byte, []byte) { return secret, body }, nil},
+		{"unsigned", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Del(HeaderSignature)
+			return secret, body
+		}, ErrMissingSignature},
+		{"no nonce", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Del(HeaderNonce)
+			return secret, body
+		}, ErrMissingSignature},
+		{"other secret", func(*http.Request) ([]byte, []byte) { return []byte("other"), body }, ErrBadSignature},
+		{"tampered mac", func(req *http.Request) ([]byte, []byte) {
+			sig := req.Header.Get(HeaderSignature)
+			req.Header.Set(HeaderSignature, strings.Repeat("0", len(sig)))
+			return secret, body
+		}, ErrBadSignature},
+		{"body changed", func(*http.Request) ([]byte, []byte) { return secret, []byte(`{"id":8}`) }, ErrBadSignature},
+		{"path changed", func(req *http.Request) ([]byte, []byte) {
+			req.URL.Path = "/trace/nric"
+			return secret, body
+		}, ErrBadSignature},
+		{"method changed", func(req *http.Request) ([]byte, []byte) {
+			req.Method = "PUT"
+			return secret, body
+		}, ErrBadSignature},
+		{"nonce changed", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Set(HeaderNonce, "00")
+			return secret, body
+		}, ErrBadSignature},
+		{"timestamp changed", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Unix()-1, 10))
+			return secret, body
+		}, ErrBadSignature},
+		{"timestamp not a number", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Set(HeaderTimestamp, "yesterday")
+			return secret, body
+		}, ErrBadSignature},
+		{"too old", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Add(-DefaultMaxSkew-time.Minute).Unix(), 10))
+			resign(req)
+			return secret, body
+		}, ErrStaleSignature},
+		{"too far ahead", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Add(DefaultMaxSkew+time.Minute).Unix(), 10))
+			resign(req)
+			return secret, body
+		}, ErrStaleSignature},
+		{"within skew", func(req *http.Request) ([]byte, []byte) {
+			req.Header.Set(HeaderTimestamp, strconv.FormatInt(now.Add(-DefaultMaxSkew+time.Minute).Unix(), 10))
+			resign(req)
+			return secret, body
+		}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := signed(t)
+			secret, body := tt.change(req)
+			err := VerifySignature(req, body, secret, now, DefaultMaxSkew)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("VerifySignature = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
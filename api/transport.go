@@ -0,0 +1,111 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+var ErrPinMismatch = errors.New("certificate pin mismatch")
+
+// TransportOptions configures how the client reaches the backend. The zero
+// value behaves like http.DefaultTransport with TLS 1.2 as the floor.
+type TransportOptions struct {
+	// Proxy is an http://, https:// or socks5:// URL. When empty the
+	// usual HTTPS_PROXY/NO_PROXY environment variables apply.
+	Proxy string
+	// CABundle is a PEM file trusted in addition to the system roots.
+	CABundle string
+	// MinTLSVersion is one of "1.2" or "1.3".
+	MinTLSVersion string
+	// PinnedKeys are base64 SHA-256 hashes of a certificate's
+	// SubjectPublicKeyInfo, optionally prefixed with "sha256/". When set,
+	// at least one certificate in the verified chain must match; extra
+	// certificates the server sends outside it don't count.
+	PinnedKeys []string
+}
+
+func WithHTTPTransport(rt http.RoundTripper) Option {
+	return func(c *Client) {
+		c.httpClient.Transport = rt
+	}
+}
+
+func NewTransport(opts TransportOptions) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if opts.Proxy != "" {
+		u, err := url.Parse(opts.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %w", err)
+		}
+		switch u.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("unsupported proxy scheme %q (want http, https or socks5)", u.Scheme)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+
+	if opts.CABundle != "" {
+		pem, err := os.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		t.TLSClientConfig.RootCAs = pool
+	}
+
+	switch opts.MinTLSVersion {
+	case "", "1.2":
+	case "1.3":
+		t.TLSClientConfig.MinVersion = tls.VersionTLS13
+	default:
+		return nil, fmt.Errorf("unsupported minimum TLS version %q (want 1.2 or 1.3)", opts.MinTLSVersion)
+	}
+
+	if len(opts.PinnedKeys) > 0 {
+		pins := make(map[string]bool, len(opts.PinnedKeys))
+		for _, p := range opts.PinnedKeys {
+			pin := strings.TrimPrefix(strings.TrimSpace(p), "sha256/")
+			if raw, err := base64.StdEncoding.DecodeString(pin); err != nil || len(raw) != sha256.Size {
+				return nil, fmt.Errorf("invalid pinned key %q: want a base64 SHA-256 hash", p)
+			}
+			pins[pin] = true
+		}
+		t.TLSClientConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+			for _, chain := range cs.VerifiedChains {
+				for _, cert := range chain {
+					sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+					if pins[base64.StdEncoding.EncodeToString(sum[:])] {
+						return nil
+					}
+				}
+			}
+			return fmt.Errorf("%w for %s", ErrPinMismatch, cs.ServerName)
+		}
+	}
+	return t, nil
+}
+
+// PublicKeyPin returns the pin for cert in the format PinnedKeys expects.
+func PublicKeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return "sha256/" + base64.StdEncoding.EncodeToString(sum[:])
+}
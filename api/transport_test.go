@@ -0,0 +1,134 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// testCert is a certificate for 127.0.0.1, signed by parent or, when
+// parent is nil, by itself as a CA.
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert) *testCert {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  parent == nil,
+	}
+	issuer, signer := tmpl, key
+	if parent != nil {
+		issuer, signer = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert: cert, key: key}
+}
+
+// writeBundle saves certs as a PEM file for TransportOptions.CABundle.
+func writeBundle(t *testing.T, certs ...*testCert) string {
+	t.Helper()
+	var data []byte
+	for _, c := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.cert.Raw})...)
+	}
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// serveTLS starts a server presenting leaf followed by extra, which need
+// not be related to it.
+func serveTLS(t *testing.T, leaf *testCert, extra ...*testCert) *httptest.Server {
+	t.Helper()
+	chain := [][]byte{leaf.cert.Raw}
+	for _, c := range extra {
+		chain = append(chain, c.cert.Raw)
+	}
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{{Certificate: chain, PrivateKey: leaf.key}}}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestPinnedKeys(t *testing.T) {
+	genuine := newTestCert(t, "genuine", nil)
+	mitmCA := newTestCert(t, "mitm ca", nil)
+	mitm := newTestCert(t, "mitm", mitmCA)
+	other := newTestCert(t, "other", nil)
+
+	tests := []struct {
+		name    string
+		srv     *httptest.Server
+		trusted []*testCert
+		pin     *testCert
+		wantErr error
+	}{
+		{"pin matches", serveTLS(t, genuine), []*testCert{genuine}, genuine, nil},
+		{"pin matches the CA", serveTLS(t, mitm), []*testCert{mitmCA}, mitmCA, nil},
+		{"pin mismatch", serveTLS(t, genuine), []*testCert{genuine}, other, ErrPinMismatch},
+		// A proxy trusted through the CA bundle appends the genuine
+		// certificate, which is outside the chain it is verified by.
+		{"appended pinned cert", serveTLS(t, mitm, genuine), []*testCert{mitmCA}, genuine, ErrPinMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := NewTransport(TransportOptions{
+				CABundle:   writeBundle(t, tt.trusted...),
+				PinnedKeys: []string{PublicKeyPin(tt.pin.cert)},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			res, err := (&http.Client{Transport: tr}).Get(tt.srv.URL)
+			if err == nil {
+				res.Body.Close()
+			}
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("got %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPinnedKeysInvalid(t *testing.T) {
+	if _, err := NewTransport(TransportOptions{PinnedKeys: []string{"sha256/not-a-hash"}}); err == nil {
+		t.Fatal("want an error for a malformed pin")
+	}
+}
@@ -1,6 +1,10 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"time"
 )
@@ -11,13 +15,13 @@ type TraceNameRequest struct {
 }
 
 type TraceNameItem struct {
-	Name  string `json:"name"`
-	Mykad string `json:"mykad"`
-	ID    int    `json:"id"`
+	Name  string `json:"name" required:"true"`
+	Mykad string `json:"mykad" required:"true"`
+	ID    int    `json:"id" required:"true"`
 }
 
 type TraceNameResponse struct {
-	Data    []TraceNameItem `json:"data"`
+	Data    []TraceNameItem `json:"data" required:"true"`
 	Message string          `json:"message"`
 	User    User            `json:"user"`
 }
@@ -25,34 +29,82 @@ type TraceNameResponse struct {
 type Client struct {
 	httpClient *http.Client
 	apiToken   string
+	baseURL    string
+	signer     *signer
+	debug      *log.Logger
+	ctx        context.Context
+	// hidden holds the record fields a field policy withholds, see
+	// Client.Withholding.
+	hidden map[string]bool
+}
+
+// StatusError is returned when the backend answers with anything but 200.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned non-OK status: %d - %s", e.Code, e.Body)
+}
+
+// IsUnauthorized reports whether the backend rejected the token itself.
+func IsUnauthorized(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && (statusErr.Code == http.StatusUnauthorized || statusErr.Code == http.StatusForbidden)
+}
+
+// IsStepUpRequired reports whether the backend wants a fresh one-time code,
+// see Client.VerifyStepUp, before it will answer.
+func IsStepUpRequired(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusPreconditionRequired
+}
+
+// IsInvalidCode reports whether a one-time code was wrong, expired or
+// already used.
+func IsInvalidCode(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusUnprocessableEntity
+}
+
+// IsNotFound reports whether the backend has no such endpoint or record.
+// Backends that predate an optional feature, such as policies, field
+// policies, rules or the acceptable-use policy, answer its endpoint with
+// 404, so callers treat this as the feature being off.
+func IsNotFound(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound
 }
 
 type TraceDetailID struct {
-	ID          int    `json:"id"`
-	Name        string `json:"name"`
-	Mykad       string `json:"mykad"`
-	Address     string `json:"address"`
-	City        string `json:"city"`
-	Postcode    string `json:"postcode"`
-	State       string `json:"state"`
-	Phone       string `json:"phone"`
-	Gender      string `json:"gender"`
-	Mobile      string `json:"mobile"`
-	Race        string `json:"race"`
-	Religion    string `json:"religion"`
-	Income      string `json:"income"`
-	Occupations string `json:"occupations"`
-	Addresses   string `json:"addresses"`
+	ID          int    `json:"id" required:"true"`
+	Name        string `json:"name" required:"true"`
+	Mykad       string `json:"mykad" required:"true"`
+	Address     string `json:"address" required:"true"`
+	City        string `json:"city" required:"true"`
+	Postcode    string `json:"postcode" required:"true"`
+	State       string `json:"state" required:"true"`
+	Phone       string `json:"phone" required:"true"`
+	Gender      string `json:"gender" required:"true"`
+	Mobile      string `json:"mobile" required:"true"`
+	Race        string `json:"race" required:"true"`
+	Religion    string `json:"religion" required:"true"`
+	Income      string `json:"income" required:"true"`
+	Occupations string `json:"occupations" required:"true"`
+	Addresses   string `json:"addresses" required:"true"`
 }
 
 type TraceDetailResponse struct {
 	Message string          `json:"message"`
-	Data    []TraceDetailID `json:"data"`
+	Data    []TraceDetailID `json:"data" required:"true"`
 	User    User            `json:"user"`
 }
 
 type TraceDetailRequest struct {
-	ID int `json:"id"`
+	ID         int      `json:"id"`
+	ApprovalID int      `json:"approval_id,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
 }
 
 type TraceNameMsg struct {
@@ -60,41 +112,69 @@ type TraceNameMsg struct {
 	Err   error
 }
 
+// TraceDetailsMsg carries a lookup's results. Withheld names the fields
+// a field policy kept out of them.
 type TraceDetailsMsg struct {
-	Details []TraceDetailID
-	Err     error
+	Details  []TraceDetailID
+	Withheld []string
+	Err      error
 }
 
 type TraceRelationsRequest struct {
-	ID     int `json:"id"`
-	Offset int `json:"offset"`
+	ID         int      `json:"id"`
+	Offset     int      `json:"offset"`
+	ApprovalID int      `json:"approval_id,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
 }
 
 type TraceRelationsItem struct {
-	UserID        int    `json:"user_id"`
-	RelatedUserID int    `json:"related_user_id"`
-	Relation      string `json:"relation"`
+	UserID        int    `json:"user_id" required:"true"`
+	RelatedUserID int    `json:"related_user_id" required:"true"`
+	Relation      string `json:"relation" required:"true"`
 }
 
 type TraceRelationsResponse struct {
-	Data          []TraceDetailID    `json:"data"`
-	Relationships TraceRelationsItem `json:"relationships"`
+	Data          []TraceDetailID    `json:"data" required:"true"`
+	Relationships TraceRelationsItem `json:"relationships" required:"true"`
 	User          User               `json:"user"`
 }
 
 type TraceRelationsMsg struct {
 	Details   []TraceDetailID
 	Relations TraceRelationsItem
+	Offset    int
+	Withheld  []string
 	Err       error
 }
 
 type User struct {
-	ID            int            `json:"id"`
-	Name          string         `json:"name"`
-	Role          string         `json:"role"`
+	ID            int            `json:"id" required:"true"`
+	Name          string         `json:"name" required:"true"`
+	Role          string         `json:"role" required:"true"`
 	APIToken      string         `json:"api_token"`
 	Balance       float64        `json:"balance"`
 	Subscriptions []Subscription `json:"subscriptions"`
+	// TOTPEnabled is set once the user has enrolled an authenticator app.
+	TOTPEnabled bool `json:"totp_enabled"`
+}
+
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+)
+
+// CanManageTeam reports whether the user may use the team administration
+// endpoints. The backend enforces this too; the client only uses it to
+// decide which screens to offer.
+func (u User) CanManageTeam() bool {
+	return u.Role == RoleOwner || u.Role == RoleAdmin
+}
+
+// CanApprove reports whether the user may sign off other operators'
+// lookup requests.
+func (u User) CanApprove() bool {
+	return u.CanManageTeam()
 }
 
 type Subscription struct {
@@ -104,11 +184,13 @@ type Subscription struct {
 }
 
 type TraceNRICRequest struct {
-	NRIC string `json:"nric"`
+	NRIC       string   `json:"nric"`
+	ApprovalID int      `json:"approval_id,omitempty"`
+	Fields     []string `json:"fields,omitempty"`
 }
 
 type TraceNRICResponse struct {
-	Data []TraceDetailID `json:"data"`
+	Data []TraceDetailID `json:"data" required:"true"`
 	User User            `json:"user"`
 }
 
@@ -117,15 +199,16 @@ type HistoryRequest struct {
 }
 
 type HistoryItem struct {
-	Type   string  `json:"type"`
-	Email  string  `json:"email"`
-	Query  string  `json:"query"`
-	Result string  `json:"result_summary"`
-	Cost   float64 `json:"cost"`
+	Type      string    `json:"type" required:"true"`
+	Email     string    `json:"email" required:"true"`
+	Query     string    `json:"query" required:"true"`
+	Result    string    `json:"result_summary" required:"true"`
+	Cost      float64   `json:"cost" required:"true"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type HistoryResponse struct {
-	Data    []HistoryItem `json:"data"`
+	Data    []HistoryItem `json:"data" required:"true"`
 	Message string        `json:"message"`
 }
 
@@ -133,3 +216,386 @@ type HistoryMsg struct {
 	Data []HistoryItem
 	Err  error
 }
+
+type AccountResponse struct {
+	Message string `json:"message"`
+	User    User   `json:"user" required:"true"`
+}
+
+// UsageMsg carries the history for a usage report. Partial means it was
+// cut off at the page limit.
+type UsageMsg struct {
+	Items   []HistoryItem
+	User    User
+	Partial bool
+	Err     error
+}
+
+type AccountMsg struct {
+	User User
+	Err  error
+}
+
+type LoginMsg struct {
+	Token string
+	User  User
+	Err   error
+}
+
+type TokenInfo struct {
+	ID         int       `json:"id"`
+	Prefix     string    `json:"prefix"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Current    bool      `json:"current"`
+}
+
+type TokensResponse struct {
+	Data    []TokenInfo `json:"data" required:"true"`
+	Message string      `json:"message"`
+}
+
+type RevokeTokenRequest struct {
+	TokenID int  `json:"token_id,omitempty"`
+	Current bool `json:"current,omitempty"`
+}
+
+type TokensMsg struct {
+	Tokens []TokenInfo
+	Err    error
+}
+
+type RotateTokenMsg struct {
+	Token         string
+	SigningSecret string
+	Err           error
+}
+
+type RevokeTokenMsg struct {
+	Current bool
+	Err     error
+}
+
+type TeamMember struct {
+	ID         int         `json:"id" required:"true"`
+	Name       string      `json:"name" required:"true"`
+	Email      string      `json:"email"`
+	Role       string      `json:"role" required:"true"`
+	SpendLimit float64     `json:"spend_limit"`
+	Spent      float64     `json:"spent"`
+	Suspended  bool        `json:"suspended"`
+	Tokens     []TokenInfo `json:"tokens"`
+}
+
+type TeamMembersResponse struct {
+	Data    []TeamMember `json:"data" required:"true"`
+	Message string       `json:"message"`
+}
+
+type TeamMemberResponse struct {
+	Data    TeamMember `json:"data" required:"true"`
+	Message string     `json:"message"`
+}
+
+type TeamTokenRequest struct {
+	MemberID int `json:"member_id"`
+	TokenID  int `json:"token_id,omitempty"`
+}
+
+// IssueTokenResponse carries a new token. SigningSecret is the hex HMAC
+// secret to sign its requests with, when the backend requires signing;
+// empty means the current secret, if any, still applies.
+type IssueTokenResponse struct {
+	Token         string    `json:"token" required:"true"`
+	SigningSecret string    `json:"signing_secret"`
+	Info          TokenInfo `json:"info"`
+	Message       string    `json:"message"`
+}
+
+type TeamLimitRequest struct {
+	MemberID   int     `json:"member_id"`
+	SpendLimit float64 `json:"spend_limit"`
+}
+
+type TeamSuspendRequest struct {
+	MemberID  int  `json:"member_id"`
+	Suspended bool `json:"suspended"`
+}
+
+type TeamMsg struct {
+	Members []TeamMember
+	Err     error
+}
+
+type TeamMemberMsg struct {
+	Member TeamMember
+	Err    error
+}
+
+type TeamTokenMsg struct {
+	Member TeamMember
+	Token  string
+	Err    error
+}
+
+// Policy is the organisation-wide settings admins enforce on every client.
+type Policy struct {
+	WatermarkRequired bool `json:"watermark_required"`
+	// ApprovalsRequired means full record lookups need a supervisor to
+	// approve them first, see Client.SubmitApproval.
+	ApprovalsRequired bool `json:"approvals_required"`
+	// StepUpRequired makes every operator enter a one-time code before
+	// detail lookups, not just those who enrolled. StepUpGraceSeconds is
+	// how long a code stays good for.
+	StepUpRequired     bool `json:"step_up_required"`
+	StepUpGraceSeconds int  `json:"step_up_grace_seconds"`
+	// ReportAnomalies sends every unusual-activity warning to the org
+	// admins. The Anomaly fields are the loosest thresholds allowed; an
+	// operator's config can tighten them but not relax them. Zero leaves
+	// a threshold to the operator.
+	ReportAnomalies      bool `json:"report_anomalies"`
+	AnomalyWindowMinutes int  `json:"anomaly_window_minutes"`
+	AnomalyNRICBurst     int  `json:"anomaly_nric_burst"`
+	AnomalyRepeatLookups int  `json:"anomaly_repeat_lookups"`
+	AnomalyRelationPages int  `json:"anomaly_relation_pages"`
+}
+
+type PolicyResponse struct {
+	Data    Policy `json:"data" required:"true"`
+	Message string `json:"message"`
+}
+
+type PolicyMsg struct {
+	Policy Policy
+	Err    error
+}
+
+// Rules are the acceptable-use rules the client checks every lookup
+// against before sending it, see package rules. Zero values leave a rule
+// off. Hours is a local time range such as "09:00-18:00", Days lists
+// weekdays as "mon" to "sun", and both are read in Timezone, an IANA name,
+// when it is set.
+type Rules struct {
+	Hours                 string   `json:"hours"`
+	Days                  []string `json:"days"`
+	Timezone              string   `json:"timezone"`
+	MaxLookupsPerHour     int      `json:"max_lookups_per_hour"`
+	JustificationRequired bool     `json:"justification_required"`
+}
+
+type RulesResponse struct {
+	Data    Rules  `json:"data" required:"true"`
+	Message string `json:"message"`
+}
+
+type RulesMsg struct {
+	Rules Rules
+	Err   error
+}
+
+// AUP is the acceptable-use policy operators must accept before tracing.
+// AcceptedVersion is the version the user last accepted, if any.
+type AUP struct {
+	Version         string `json:"version" required:"true"`
+	Text            string `json:"text" required:"true"`
+	AcceptedVersion string `json:"accepted_version"`
+}
+
+type AUPResponse struct {
+	Data    AUP    `json:"data" required:"true"`
+	Message string `json:"message"`
+}
+
+type AUPMsg struct {
+	AUP AUP
+	Err error
+}
+
+type AcceptAUPRequest struct {
+	Version string `json:"version"`
+}
+
+// AUPAcceptance records who accepted which version of the policy, and when.
+type AUPAcceptance struct {
+	UserID     int       `json:"user_id" required:"true"`
+	Version    string    `json:"version" required:"true"`
+	AcceptedAt time.Time `json:"accepted_at" required:"true"`
+}
+
+type AUPAcceptanceResponse struct {
+	Data    AUPAcceptance `json:"data" required:"true"`
+	Message string        `json:"message"`
+}
+
+type AUPAcceptMsg struct {
+	Acceptance AUPAcceptance
+	Err        error
+}
+
+// AnomalyReport tells org admins about an unusual lookup pattern the
+// client spotted in its own audit log.
+type AnomalyReport struct {
+	Kind       string    `json:"kind"`
+	Count      int       `json:"count"`
+	RecordID   int       `json:"record_id,omitempty"`
+	Message    string    `json:"message"`
+	Session    string    `json:"session"`
+	DetectedAt time.Time `json:"detected_at"`
+}
+
+type FieldPolicyRequest struct {
+	Role string `json:"role"`
+}
+
+// FieldPolicy names the record fields, by their JSON names, that a role
+// may not see.
+type FieldPolicy struct {
+	Role   string   `json:"role" required:"true"`
+	Hidden []string `json:"hidden" required:"true"`
+}
+
+type FieldPolicyResponse struct {
+	Data    FieldPolicy `json:"data" required:"true"`
+	Message string      `json:"message"`
+}
+
+type FieldPolicyMsg struct {
+	Policy FieldPolicy
+	Err    error
+}
+
+// Approval kinds, one per lookup that can need sign-off. A relations
+// approval covers one page, the Offset, of RecordID's relationships.
+const (
+	ApprovalTraceDetail    = "trace_detail"
+	ApprovalTraceNRIC      = "trace_nric"
+	ApprovalTraceRelations = "trace_relations"
+)
+
+// Approval statuses. An approved request can be used for one lookup, after
+// which it is used.
+const (
+	ApprovalPending  = "pending"
+	ApprovalApproved = "approved"
+	ApprovalRejected = "rejected"
+	ApprovalUsed     = "used"
+)
+
+type Approval struct {
+	ID            int       `json:"id" required:"true"`
+	Kind          string    `json:"kind" required:"true"`
+	RecordID      int       `json:"record_id,omitempty"`
+	NRIC          string    `json:"nric,omitempty"`
+	Offset        int       `json:"offset,omitempty"`
+	Justification string    `json:"justification" required:"true"`
+	Status        string    `json:"status" required:"true"`
+	RequestedBy   string    `json:"requested_by" required:"true"`
+	DecidedBy     string    `json:"decided_by,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	DecidedAt     time.Time `json:"decided_at"`
+}
+
+type SubmitApprovalRequest struct {
+	Kind          string `json:"kind"`
+	RecordID      int    `json:"record_id,omitempty"`
+	NRIC          string `json:"nric,omitempty"`
+	Offset        int    `json:"offset,omitempty"`
+	Justification string `json:"justification"`
+}
+
+type DecideApprovalRequest struct {
+	ID      int  `json:"id"`
+	Approve bool `json:"approve"`
+}
+
+type ApprovalsResponse struct {
+	Data    []Approval `json:"data" required:"true"`
+	Message string     `json:"message"`
+}
+
+type ApprovalResponse struct {
+	Data    Approval `json:"data" required:"true"`
+	Message string   `json:"message"`
+}
+
+// ApprovalsMsg carries the caller's own requests and, for supervisors, the
+// requests waiting on them.
+type ApprovalsMsg struct {
+	Mine    []Approval
+	Pending []Approval
+	Err     error
+}
+
+type ApprovalMsg struct {
+	Approval Approval
+	Err      error
+}
+
+// Report statuses. A report stays open until the data team has checked
+// it, then the record is either corrected or the report dismissed.
+const (
+	ReportOpen      = "open"
+	ReportCorrected = "corrected"
+	ReportDismissed = "dismissed"
+)
+
+// Report flags fields of a record as wrong or out of date. Resolution is
+// the data team's note once it is no longer open.
+type Report struct {
+	ID         int       `json:"id" required:"true"`
+	RecordID   int       `json:"record_id" required:"true"`
+	Fields     []string  `json:"fields" required:"true"`
+	Comment    string    `json:"comment"`
+	Status     string    `json:"status" required:"true"`
+	Resolution string    `json:"resolution,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type SubmitReportRequest struct {
+	RecordID int      `json:"record_id"`
+	Fields   []string `json:"fields"`
+	Comment  string   `json:"comment"`
+}
+
+type ReportsResponse struct {
+	Data    []Report `json:"data" required:"true"`
+	Message string   `json:"message"`
+}
+
+type ReportResponse struct {
+	Data    Report `json:"data" required:"true"`
+	Message string `json:"message"`
+}
+
+type ReportsMsg struct {
+	Reports []Report
+	Err     error
+}
+
+type ReportMsg struct {
+	Report Report
+	Err    error
+}
+
+type EnrollTOTPResponse struct {
+	Secret  string `json:"secret" required:"true"`
+	URI     string `json:"uri" required:"true"`
+	Message string `json:"message"`
+}
+
+type TOTPCodeRequest struct {
+	Code string `json:"code"`
+}
+
+type StepUpResponse struct {
+	GraceSeconds int    `json:"grace_seconds" required:"true"`
+	Message      string `json:"message"`
+}
+
+// StepUpMsg reports a verified code and how long lookups may run without
+// another one.
+type StepUpMsg struct {
+	Grace time.Duration
+	Err   error
+}
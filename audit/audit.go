@@ -0,0 +1,184 @@
+// Package audit keeps a local, append-only log
+// of what each session looked at
+package audit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"synthera/utils"
+	"time"
+)
+
+// Actions recorded in the log.
+const (
+	ActionSessionStart = "session.start"
+	ActionRecordView   = "record.view"
+	ActionApproval     = "approval"
+	ActionStepUp       = "step_up"
+	ActionLookup       = "lookup"
+	ActionDenied       = "policy.denied"
+	ActionAnomaly      = "anomaly"
+	ActionAnomalyAck   = "anomaly.ack"
+	ActionAUPAccept    = "aup.accept"
+	ActionReport       = "record.report"
+)
+
+// Kinds of lookup, recorded with ActionLookup.
+const (
+	LookupName      = "name"
+	LookupNRIC      = "nric"
+	LookupDetail    = "detail"
+	LookupRelations = "relations"
+)
+
+// Event is one line of the log. It names who did what and to which record,
+// but never holds search terms or record contents.
+type Event struct {
+	Time     time.Time `json:"time"`
+	Session  string    `json:"session"`
+	Profile  string    `json:"profile"`
+	Action   string    `json:"action"`
+	Kind     string    `json:"kind,omitempty"`
+	Operator string    `json:"operator,omitempty"`
+	UserID   int       `json:"user_id,omitempty"`
+	RecordID int       `json:"record_id,omitempty"`
+	Detail   string    `json:"detail,omitempty"`
+}
+
+// NewSession returns a short random ID for one run of the TUI. It is shown
+// in the watermark, so a screenshot can be matched to its log entries.
+func NewSession() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Path is the log file, audit.jsonl in the state directory.
+func Path() (string, error) {
+	dir, err := utils.StateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "audit.jsonl"), nil
+}
+
+// Append writes e as a single line. Time and Profile are filled in when
+// unset. Each event is one write to a file opened for appending, so
+// concurrent sessions never interleave within a line.
+func Append(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	if e.Profile == "" {
+		e.Profile = utils.Profile
+	}
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Read returns the events logged at or after since, oldest first. Lines
+// that don't parse, such as one cut short by a crash, are skipped. A
+// missing log reads as empty.
+func Read(since time.Time) ([]Event, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if json.Unmarshal(scanner.Bytes(), &e) != nil || e.Time.Before(since) {
+			continue
+		}
+		events = append(events, e)
+	}
+	return events, scanner.Err()
+}
+
+// Recent keeps the events of the last keep in memory, so checks made on
+// every lookup don't read the log back. It is seeded from the log once and
+// then sees each event appended through it, so editing or deleting the log
+// mid-session changes nothing. It is safe for concurrent use.
+type Recent struct {
+	keep time.Duration
+	err  error
+
+	mu     sync.Mutex
+	events []Event
+}
+
+// NewRecent seeds a Recent with the events logged in the last keep.
+func NewRecent(keep time.Duration) *Recent {
+	events, err := Read(time.Now().Add(-keep))
+	return &Recent{keep: keep, err: err, events: events}
+}
+
+// Err is why the log couldn't be read when seeding, in which case only
+// the events appended since are known.
+func (r *Recent) Err() error {
+	return r.err
+}
+
+// Append logs e as the package Append does and keeps it. It is kept even
+// when the log can't be written, so a full disk doesn't reset the counts.
+func (r *Recent) Append(e Event) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+	err := Append(e)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cutoff := e.Time.Add(-r.keep)
+	i := 0
+	for i < len(r.events) && r.events[i].Time.Before(cutoff) {
+		i++
+	}
+	r.events = append(r.events[i:], e)
+	return err
+}
+
+// Since returns the kept events at or after t, oldest first.
+func (r *Recent) Since(t time.Time) []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var events []Event
+	for _, e := range r.events {
+		if !e.Time.Before(t) {
+			events = append(events, e)
+		}
+	}
+	return events
+}
@@ -3,6 +3,22 @@ set -e
 
 mkdir -p builds
 
+# VERSION defaults to the nearest git tag; SIGNING_KEY is an ed25519 PEM
+# private key and UPDATE_PUBKEY the matching base64 public key that
+# `synthera update` verifies downloads against. RULES_PUBKEY is the base64
+# ed25519 key local rules files must be signed with.
+VERSION=${VERSION:-$(git describe --tags --always --dirty 2>/dev/null || echo dev)}
+BUILD_DATE=$(date -u +%Y-%m-%dT%H:%M:%SZ)
+ldflags="-s -w -X synthera/version.Version=$VERSION -X synthera/version.BuildDate=$BUILD_DATE"
+if [ -n "$UPDATE_PUBKEY" ]; then
+    ldflags="$ldflags -X synthera/update.PublicKey=$UPDATE_PUBKEY"
+fi
+if [ -n "$RULES_PUBKEY" ]; then
+    ldflags="$ldflags -X synthera/rules.PublicKey=$RULES_PUBKEY"
+fi
+
+echo "$VERSION" > builds/VERSION
+
 platforms=(
     "linux amd64"
     "darwin amd64"
@@ -36,8 +52,18 @@ do
     echo "Building $output ..."
 
     if [ "$GOOS" = "android" ]; then
-        GOOS=$GOOS GOARCH=$GOARCH CGO_ENABLED=1 CC=/opt/android-ndk/toolchains/llvm/prebuilt/linux-x86_64/bin/aarch64-linux-android21-clang go build -ldflags="-s -w" -o "builds/$output"
+        GOOS=$GOOS GOARCH=$GOARCH CGO_ENABLED=1 CC=/opt/android-ndk/toolchains/llvm/prebuilt/linux-x86_64/bin/aarch64-linux-android21-clang go build -ldflags="$ldflags" -o "builds/$output"
     else
-        GOOS=$GOOS GOARCH=$GOARCH go build -ldflags="-s -w" -o "builds/$output"
+        GOOS=$GOOS GOARCH=$GOARCH go build -ldflags="$ldflags" -o "builds/$output"
+    fi
+
+    (cd builds && sha256sum "$output" > "$output.sha256")
+    if [ -n "$SIGNING_KEY" ]; then
+        # The signature covers the version and checksum, not just the
+        # binary, so an old release can't be served as the latest one.
+        sum=$(cut -d' ' -f1 "builds/$output.sha256")
+        printf 'synthera-release\n%s\n%s\n%s\n' "$VERSION" "$output" "$sum" > "builds/$output.msg"
+        openssl pkeyutl -sign -inkey "$SIGNING_KEY" -rawin -in "builds/$output.msg" -out "builds/$output.sig"
+        rm "builds/$output.msg"
     fi
 done
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"synthera/api"
+	"synthera/utils"
+
+	"github.com/charmbracelet/x/term"
+)
+
+func runLogin(e *env, args []string) int {
+	fs := e.flags("login")
+	fromStdin := fs.Bool("stdin", false, "read the token from stdin, e.g. piped from a password manager")
+	signed := fs.Bool("signed", false, "the token has a signing secret, read after the token")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	token, secret, err := readCredentials(e, *fromStdin, *signed)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitUsage
+	}
+	rawSecret, err := hex.DecodeString(secret)
+	if err != nil {
+		e.errorf("Invalid signing secret, want hex: %v", err)
+		return exitUsage
+	}
+
+	// Check the token before it replaces a working one on disk, signed
+	// with its own secret rather than any stored for the old token.
+	opts, err := e.clientOptions()
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+	opts = append(opts, api.WithSigningSecret(rawSecret))
+	user, err := api.NewClient(token, opts...).Account()
+	if api.IsUnauthorized(err) {
+		e.errorf("The backend rejected that token, nothing was saved")
+		return exitAuth
+	}
+	if err != nil {
+		return e.fail("Error verifying token", err)
+	}
+
+	if err := utils.SaveCredentials(token, secret); err != nil {
+		e.errorf("Can't save token: %v", err)
+		return exitError
+	}
+	fmt.Fprintf(e.stdout, "Logged in as %s on profile %s\n", whoamiLine(user), utils.Profile)
+	return exitOK
+}
+
+// readCredentials prompts on the terminal without echoing, or takes lines
+// from stdin when asked to: the token, then the signing secret if signed.
+func readCredentials(e *env, fromStdin, signed bool) (token, secret string, err error) {
+	var in *bufio.Reader
+	switch {
+	case fromStdin:
+		in = bufio.NewReader(os.Stdin)
+	case !term.IsTerminal(os.Stdin.Fd()):
+		return "", "", fmt.Errorf("stdin is not a terminal, use --stdin to pipe the token in")
+	}
+
+	token, err = readSecret(e, in, "API token", "token")
+	if err != nil || !signed {
+		return token, "", err
+	}
+	secret, err = readSecret(e, in, "Signing secret", "signing secret")
+	return token, secret, err
+}
+
+// readSecret reads one line from in, or from the terminal without echo
+// after prompt when in is nil.
+func readSecret(e *env, in *bufio.Reader, prompt, what string) (string, error) {
+	var s string
+	if in != nil {
+		line, err := in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", fmt.Errorf("can't read %s from stdin: %w", what, err)
+		}
+		s = line
+	} else {
+		fmt.Fprint(e.stderr, prompt+": ")
+		b, err := readPassword(e.ctx)
+		fmt.Fprintln(e.stderr)
+		if err != nil {
+			return "", fmt.Errorf("can't read %s: %w", what, err)
+		}
+		s = string(b)
+	}
+
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return "", fmt.Errorf("no %s entered", what)
+	}
+	return s, nil
+}
+
+// readPassword is term.ReadPassword, except that cancelling ctx gives up
+// on the read and turns echo back on rather than leaving the terminal
+// silent after the process exits.
+func readPassword(ctx context.Context) ([]byte, error) {
+	fd := os.Stdin.Fd()
+	state, err := term.GetState(fd)
+	if err != nil {
+		return nil, err
+	}
+
+	type result struct {
+		b   []byte
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		b, err := term.ReadPassword(fd)
+		done <- result{b, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.b, r.err
+	case <-ctx.Done():
+		term.Restore(fd, state)
+		return nil, ctx.Err()
+	}
+}
+
+func runLogout(e *env, args []string) int {
+	fs := e.flags("logout")
+	revoke := fs.Bool("revoke", false, "also revoke the token on the server")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	if e.cfg.APIToken == "" {
+		fmt.Fprintf(e.stdout, "Profile %s is not logged in\n", utils.Profile)
+		return exitOK
+	}
+
+	if *revoke {
+		client, code := e.client()
+		if client == nil {
+			return code
+		}
+		// A token the backend already rejects is as good as revoked, so
+		// only keep it around when revoking failed for another reason.
+		if err := client.RevokeCurrentToken(); err != nil && !api.IsUnauthorized(err) {
+			return e.fail("Error revoking token, it is still stored locally", err)
+		}
+	}
+
+	if err := utils.ClearCredentials(); err != nil {
+		e.errorf("Can't remove token: %v", err)
+		return exitError
+	}
+	if *revoke {
+		fmt.Fprintf(e.stdout, "Token revoked and removed from profile %s\n", utils.Profile)
+	} else {
+		fmt.Fprintf(e.stdout, "Logged out of profile %s, the token is still valid on the server\n", utils.Profile)
+	}
+	return exitOK
+}
+
+func runWhoami(e *env, args []string) int {
+	fs := e.flags("whoami")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	client, code := e.client()
+	if client == nil {
+		return code
+	}
+	user, err := client.Account()
+	if err != nil {
+		return e.fail("Error fetching account", err)
+	}
+	fmt.Fprintln(e.stdout, whoamiLine(user))
+	return exitOK
+}
+
+func runAccount(e *env, args []string) int {
+	fs := e.flags("account")
+	asJSON := fs.Bool("json", false, "print machine-readable JSON")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	client, code := e.client()
+	if client == nil {
+		return code
+	}
+	user, err := client.Account()
+	if err != nil {
+		return e.fail("Error fetching account", err)
+	}
+	// Never echo the token back, even though the account payload has it.
+	user.APIToken = ""
+
+	if *asJSON {
+		return e.printJSON(user)
+	}
+
+	fmt.Fprintf(e.stdout, "Name:     %s\n", user.Name)
+	fmt.Fprintf(e.stdout, "Role:     %s\n", user.Role)
+	fmt.Fprintf(e.stdout, "Balance:  %.2f\n", user.Balance)
+	for _, sub := range user.Subscriptions {
+		state := "inactive"
+		if sub.Active {
+			state = "active"
+		}
+		fmt.Fprintf(e.stdout, "Plan:     %s, %s, expires %s\n", sub.Plan, state, sub.ExpiredAt.Local().Format("2006-01-02"))
+	}
+	return exitOK
+}
+
+func whoamiLine(user api.User) string {
+	return fmt.Sprintf("%s (%s)", user.Name, user.Role)
+}
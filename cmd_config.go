@@ -0,0 +1,201 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"synthera/utils"
+)
+
+// configKey maps a dotted key onto a Config field. Secret values are
+// redacted on get so `config get` output is safe to paste.
+type configKey struct {
+	get    func(c *utils.Config) string
+	set    func(c *utils.Config, v string) error
+	secret bool
+}
+
+var configKeys = map[string]configKey{
+	"api_token": {
+		get:    func(c *utils.Config) string { return c.APIToken },
+		set:    func(c *utils.Config, v string) error { c.APIToken = v; return nil },
+		secret: true,
+	},
+	"signing_secret": {
+		get:    func(c *utils.Config) string { return c.SigningSecret },
+		set:    func(c *utils.Config, v string) error { c.SigningSecret = v; return nil },
+		secret: true,
+	},
+	"base_url": {
+		get: func(c *utils.Config) string { return c.BaseURL },
+		set: func(c *utils.Config, v string) error { c.BaseURL = v; return nil },
+	},
+	"network.proxy": {
+		get: func(c *utils.Config) string { return c.Network.Proxy },
+		set: func(c *utils.Config, v string) error { c.Network.Proxy = v; return nil },
+	},
+	"network.ca_bundle": {
+		get: func(c *utils.Config) string { return c.Network.CABundle },
+		set: func(c *utils.Config, v string) error { c.Network.CABundle = v; return nil },
+	},
+	"network.min_tls_version": {
+		get: func(c *utils.Config) string { return c.Network.MinTLSVersion },
+		set: func(c *utils.Config, v string) error { c.Network.MinTLSVersion = v; return nil },
+	},
+	"network.pinned_keys": {
+		get: func(c *utils.Config) string { return strings.Join(c.Network.PinnedKeys, ",") },
+		set: func(c *utils.Config, v string) error { c.Network.PinnedKeys = splitList(v); return nil },
+	},
+	"check_updates": {
+		get: func(c *utils.Config) string { return strconv.FormatBool(c.CheckUpdates) },
+		set: func(c *utils.Config, v string) error { return setBool(&c.CheckUpdates, v) },
+	},
+	"mouse": {
+		get: func(c *utils.Config) string { return strconv.FormatBool(c.MouseEnabled()) },
+		set: func(c *utils.Config, v string) error {
+			var on bool
+			if err := setBool(&on, v); err != nil {
+				return err
+			}
+			c.Mouse = &on
+			return nil
+		},
+	},
+	"watermark": {
+		get: func(c *utils.Config) string { return strconv.FormatBool(c.WatermarkEnabled()) },
+		set: func(c *utils.Config, v string) error {
+			var on bool
+			if err := setBool(&on, v); err != nil {
+				return err
+			}
+			c.Watermark = &on
+			return nil
+		},
+	},
+	"anomaly.window_minutes": {
+		get: func(c *utils.Config) string { return strconv.Itoa(c.Anomaly.WindowMinutes) },
+		set: func(c *utils.Config, v string) error { return setInt(&c.Anomaly.WindowMinutes, v) },
+	},
+	"anomaly.nric_burst": {
+		get: func(c *utils.Config) string { return strconv.Itoa(c.Anomaly.NRICBurst) },
+		set: func(c *utils.Config, v string) error { return setInt(&c.Anomaly.NRICBurst, v) },
+	},
+	"anomaly.repeat_lookups": {
+		get: func(c *utils.Config) string { return strconv.Itoa(c.Anomaly.RepeatLookups) },
+		set: func(c *utils.Config, v string) error { return setInt(&c.Anomaly.RepeatLookups, v) },
+	},
+	"anomaly.relation_pages": {
+		get: func(c *utils.Config) string { return strconv.Itoa(c.Anomaly.RelationPages) },
+		set: func(c *utils.Config, v string) error { return setInt(&c.Anomaly.RelationPages, v) },
+	},
+	"anomaly.report": {
+		get: func(c *utils.Config) string { return strconv.FormatBool(c.Anomaly.Report) },
+		set: func(c *utils.Config, v string) error { return setBool(&c.Anomaly.Report, v) },
+	},
+	"release_url": {
+		get: func(c *utils.Config) string { return c.ReleaseURL },
+		set: func(c *utils.Config, v string) error { c.ReleaseURL = v; return nil },
+	},
+}
+
+func runConfig(e *env, args []string) int {
+	fs := e.flags("config")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	rest := fs.Args()
+	if len(rest) == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	switch rest[0] {
+	case "get":
+		if len(rest) == 1 {
+			var names []string
+			for name := range configKeys {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(e.stdout, "%s = %s\n", name, configValue(e.cfg, name))
+			}
+			return exitOK
+		}
+		if _, ok := configKeys[rest[1]]; !ok {
+			e.errorf("Unknown config key %q", rest[1])
+			return exitUsage
+		}
+		fmt.Fprintln(e.stdout, configValue(e.cfg, rest[1]))
+		return exitOK
+	case "set":
+		if len(rest) != 3 {
+			e.errorf("Usage: synthera config set <key> <value>")
+			return exitUsage
+		}
+		key, ok := configKeys[rest[1]]
+		if !ok {
+			e.errorf("Unknown config key %q", rest[1])
+			return exitUsage
+		}
+		err := utils.UpdateConfig(func(c *utils.Config) error {
+			return key.set(c, rest[2])
+		})
+		if err != nil {
+			e.errorf("Can't set %s: %v", rest[1], err)
+			return exitError
+		}
+		return exitOK
+	}
+
+	e.errorf("Unknown config action %q, want get or set", rest[0])
+	return exitUsage
+}
+
+func configValue(c *utils.Config, name string) string {
+	key := configKeys[name]
+	v := key.get(c)
+	if key.secret && v != "" {
+		return redact(v)
+	}
+	return v
+}
+
+// redact keeps just enough of a secret to tell two apart.
+func redact(s string) string {
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "****" + s[len(s)-2:]
+}
+
+func splitList(v string) []string {
+	var out []string
+	for _, part := range strings.Split(v, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
+
+func setBool(dst *bool, v string) error {
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return fmt.Errorf("want true or false, got %q", v)
+	}
+	*dst = b
+	return nil
+}
+
+// setInt accepts a count of zero or more; zero restores the default.
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return fmt.Errorf("want a whole number of zero or more, got %q", v)
+	}
+	*dst = n
+	return nil
+}
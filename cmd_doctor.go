@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"runtime"
+	"strings"
+	"synthera/api"
+	"synthera/audit"
+	"synthera/update"
+	"synthera/utils"
+	"synthera/version"
+	"time"
+
+	"github.com/charmbracelet/x/term"
+	"github.com/muesli/termenv"
+)
+
+const (
+	statusOK   = "ok  "
+	statusWarn = "warn"
+	statusFail = "FAIL"
+)
+
+// clockWarnSkew is when clock drift is worth mentioning; past
+// api.DefaultMaxSkew signed requests start getting rejected.
+const clockWarnSkew = 30 * time.Second
+
+// doctor prints one line per check. Every detail goes through scrub so the
+// output can be pasted into a support ticket as is.
+type doctor struct {
+	e       *env
+	secrets []string
+	failed  bool
+}
+
+func (d *doctor) report(status, name, format string, a ...any) {
+	if status == statusFail {
+		d.failed = true
+	}
+	fmt.Fprintf(d.e.stdout, "[%s] %-9s %s\n", status, name, d.scrub(fmt.Sprintf(format, a...)))
+}
+
+func (d *doctor) scrub(s string) string {
+	for _, secret := range d.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redact(secret))
+		}
+	}
+	return s
+}
+
+func runDoctor(e *env, args []string) int {
+	fs := e.flags("doctor")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	d := &doctor{e: e, secrets: []string{e.cfg.APIToken, e.cfg.SigningSecret}}
+	info := e.buildInfo()
+	fmt.Fprintf(e.stdout, "synthera %s, %s %s/%s, profile %s\n\n", info.Short(), runtime.Version(), runtime.GOOS, runtime.GOARCH, info.Profile)
+
+	d.checkConfig()
+	d.checkAudit()
+	d.checkRules()
+
+	transport, err := e.transport()
+	if err != nil {
+		d.report(statusFail, "network", "%v", err)
+	} else {
+		base, err := url.Parse(info.BaseURL)
+		if err != nil || base.Host == "" {
+			d.report(statusFail, "base url", "invalid base URL %q", info.BaseURL)
+		} else {
+			d.report(statusOK, "base url", "%s", base.Redacted())
+			proxied := d.checkProxy(transport, base)
+			d.checkDNS(base, proxied)
+			d.checkConnection(transport, base)
+		}
+	}
+
+	d.checkToken()
+	d.checkTerminal()
+	d.checkVersion()
+
+	if d.failed {
+		return exitError
+	}
+	return exitOK
+}
+
+func (d *doctor) checkConfig() {
+	path, err := utils.ConfigPath()
+	if err != nil {
+		d.report(statusFail, "config", "%v", err)
+		return
+	}
+	st, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		d.report(statusWarn, "config", "%s does not exist yet, run synthera login", path)
+	case err != nil:
+		d.report(statusFail, "config", "%v", err)
+	case runtime.GOOS == "windows":
+		d.report(statusOK, "config", "%s", path)
+	case st.Mode().Perm()&0077 != 0:
+		d.report(statusFail, "config", "%s is readable by other users (%s), run chmod 600", path, st.Mode().Perm())
+	default:
+		d.report(statusOK, "config", "%s (%s)", path, st.Mode().Perm())
+	}
+}
+
+// checkAudit makes sure the audit log can be appended to, without adding
+// an entry.
+func (d *doctor) checkAudit() {
+	path, err := audit.Path()
+	if err != nil {
+		d.report(statusFail, "audit", "%v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		d.report(statusFail, "audit", "can't write the audit log: %v", err)
+		return
+	}
+	f.Close()
+	d.report(statusOK, "audit", "%s", path)
+}
+
+// checkRules verifies a local rules file, when one is configured. A file
+// that fails here blocks every lookup in the TUI against a backend without
+// rules of its own.
+func (d *doctor) checkRules() {
+	if d.e.cfg.RulesFile == "" {
+		return
+	}
+	if _, _, err := d.e.localRules(); err != nil {
+		d.report(statusFail, "rules", "%v", err)
+		return
+	}
+	d.report(statusOK, "rules", "%s, signature verified, used when the server has no rules", d.e.cfg.RulesFile)
+}
+
+// checkProxy reports which proxy requests to base go through and whether
+// one is in use at all.
+func (d *doctor) checkProxy(t *http.Transport, base *url.URL) bool {
+	source := "network.proxy"
+	if d.e.cfg.Network.Proxy == "" {
+		source = "environment"
+	}
+	proxy, err := t.Proxy(&http.Request{URL: base})
+	switch {
+	case err != nil:
+		d.report(statusFail, "proxy", "%v", err)
+	case proxy == nil:
+		d.report(statusOK, "proxy", "none, connecting directly")
+	default:
+		d.report(statusOK, "proxy", "%s (from %s)", proxy.Redacted(), source)
+	}
+	return proxy != nil
+}
+
+func (d *doctor) checkDNS(base *url.URL, proxied bool) {
+	ctx, cancel := context.WithTimeout(d.e.ctx, 5*time.Second)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, base.Hostname())
+	switch {
+	case err != nil && proxied:
+		d.report(statusWarn, "dns", "%v (the proxy may still resolve it)", err)
+	case err != nil:
+		d.report(statusFail, "dns", "%v", err)
+	default:
+		d.report(statusOK, "dns", "%s -> %s", base.Hostname(), strings.Join(addrs, ", "))
+	}
+}
+
+// checkConnection makes one unauthenticated request with the configured
+// transport, which exercises the proxy, TLS settings and pins, and uses
+// the response Date header to measure clock skew.
+func (d *doctor) checkConnection(t *http.Transport, base *url.URL) {
+	client := &http.Client{Transport: t, Timeout: 15 * time.Second}
+	start := time.Now()
+	req, err := http.NewRequestWithContext(d.e.ctx, http.MethodGet, base.String(), nil)
+	if err != nil {
+		d.report(statusFail, "tls", "%v", err)
+		return
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		d.report(statusFail, "tls", "%v", err)
+		return
+	}
+	resp.Body.Close()
+	latency := time.Since(start).Round(time.Millisecond)
+
+	if resp.TLS == nil {
+		d.report(statusWarn, "tls", "%s is plain HTTP, traffic is not encrypted", base.Scheme+"://"+base.Host)
+	} else {
+		cert := resp.TLS.PeerCertificates[0]
+		d.report(statusOK, "tls", "%s, %s, issued by %s, expires %s (%s)",
+			tls.VersionName(resp.TLS.Version), tls.CipherSuiteName(resp.TLS.CipherSuite),
+			cert.Issuer.CommonName, cert.NotAfter.Format("2006-01-02"), latency)
+	}
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		d.report(statusWarn, "clock", "server sent no Date header, can't measure skew")
+		return
+	}
+	// The Date header has one second resolution and was stamped somewhere
+	// during the round trip, so compare against its midpoint.
+	skew := start.Add(latency / 2).Sub(date).Round(time.Second)
+	switch {
+	case skew.Abs() >= api.DefaultMaxSkew:
+		d.report(statusFail, "clock", "local clock is %s off the server, signed requests will be rejected", skew)
+	case skew.Abs() >= clockWarnSkew:
+		d.report(statusWarn, "clock", "local clock is %s off the server", skew)
+	default:
+		d.report(statusOK, "clock", "within %s of the server", clockWarnSkew)
+	}
+}
+
+func (d *doctor) checkToken() {
+	if d.e.cfg.APIToken == "" {
+		d.report(statusFail, "token", "not logged in, run synthera login")
+		return
+	}
+	// e.client reports its own errors; keep them in the report instead.
+	opts, err := d.e.clientOptions()
+	if err != nil {
+		d.report(statusFail, "token", "%v", err)
+		return
+	}
+	user, err := api.NewClient(d.e.cfg.APIToken, opts...).Account()
+	switch {
+	case api.IsUnauthorized(err):
+		d.report(statusFail, "token", "%s was rejected by the backend, run synthera login", d.e.cfg.APIToken)
+	case api.IsIncompatible(err):
+		d.report(statusFail, "token", "can't check: %v", err)
+	case err != nil:
+		d.report(statusFail, "token", "%v", err)
+	default:
+		signing := "unsigned requests"
+		if d.e.cfg.SigningSecret != "" {
+			signing = "signed requests"
+		}
+		d.report(statusOK, "token", "%s valid for %s, %s", d.e.cfg.APIToken, whoamiLine(user), signing)
+	}
+}
+
+func (d *doctor) checkTerminal() {
+	fd := os.Stdout.Fd()
+	if !term.IsTerminal(fd) {
+		d.report(statusWarn, "terminal", "stdout is not a terminal, the interactive interface needs one")
+		return
+	}
+	width, height, err := term.GetSize(fd)
+	if err != nil {
+		d.report(statusWarn, "terminal", "can't read size: %v", err)
+		return
+	}
+	profile := termenv.NewOutput(os.Stdout).EnvColorProfile().Name()
+	d.report(statusOK, "terminal", "%dx%d, %s colour, TERM=%s", width, height, profile, os.Getenv("TERM"))
+}
+
+func (d *doctor) checkVersion() {
+	u, err := d.e.updater()
+	if err != nil {
+		d.report(statusWarn, "version", "%v", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(d.e.ctx, 10*time.Second)
+	defer cancel()
+
+	rel, err := u.Latest(ctx)
+	switch {
+	case err != nil:
+		d.report(statusWarn, "version", "can't check for updates: %v", err)
+	case update.Newer(rel.Version, version.Version):
+		d.report(statusWarn, "version", "%s is available (running %s), run synthera update", rel.Version, version.Version)
+	default:
+		d.report(statusOK, "version", "%s is the latest release", version.Version)
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// teamArgs is how many arguments each team action takes after its name;
+// suspend takes an optional on or off as well.
+var teamArgs = map[string]int{
+	"list":    0,
+	"issue":   1,
+	"revoke":  2,
+	"limit":   2,
+	"suspend": 1,
+}
+
+func runTeam(e *env, args []string) int {
+	fs := e.flags("team")
+	asJSON := fs.Bool("json", false, "print machine-readable JSON")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+	name, rest, code, ok := e.action(fs)
+	if !ok {
+		return code
+	}
+
+	want, known := teamArgs[name]
+	if !known {
+		e.errorf("Unknown team action %q, want list, issue, revoke, limit or suspend", name)
+		return exitUsage
+	}
+	if len(rest) != want && !(name == "suspend" && len(rest) == 2) {
+		fs.Usage()
+		return exitUsage
+	}
+	var memberID, tokenID int
+	var limit float64
+	suspend := true
+	if want > 0 {
+		id, err := strconv.Atoi(rest[0])
+		if err != nil || id <= 0 {
+			e.errorf("Member ID must be a number, see synthera team list")
+			return exitUsage
+		}
+		memberID = id
+	}
+	switch {
+	case name == "revoke":
+		id, err := strconv.Atoi(rest[1])
+		if err != nil || id <= 0 {
+			e.errorf("Token ID must be a number, see synthera team list")
+			return exitUsage
+		}
+		tokenID = id
+	case name == "limit":
+		l, err := strconv.ParseFloat(strings.TrimSpace(rest[1]), 64)
+		if err != nil || l < 0 {
+			e.errorf("Spending limit must be an amount of 0 or more, 0 meaning no limit")
+			return exitUsage
+		}
+		limit = l
+	case name == "suspend" && len(rest) == 2:
+		switch rest[1] {
+		case "on":
+		case "off":
+			suspend = false
+		default:
+			e.errorf("Want on or off, got %q", rest[1])
+			return exitUsage
+		}
+	}
+
+	client, code := e.client()
+	if client == nil {
+		return code
+	}
+
+	switch name {
+	case "list":
+		members, err := client.TeamMembers()
+		if err != nil {
+			return e.fail("Error fetching team", err)
+		}
+		if *asJSON {
+			return e.printJSON(members)
+		}
+		tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID\tName\tEmail\tRole\tSpent\tLimit\tStatus\tTokens\n")
+		for _, m := range members {
+			limit, status := "none", "active"
+			if m.SpendLimit > 0 {
+				limit = fmt.Sprintf("%.2f", m.SpendLimit)
+			}
+			if m.Suspended {
+				status = "suspended"
+			}
+			tokens := make([]string, len(m.Tokens))
+			for i, t := range m.Tokens {
+				tokens[i] = fmt.Sprintf("%d (%s…)", t.ID, t.Prefix)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%.2f\t%s\t%s\t%s\n", m.ID, m.Name, m.Email, m.Role, m.Spent, limit, status, strings.Join(tokens, ", "))
+		}
+		if err := tw.Flush(); err != nil {
+			return exitError
+		}
+	case "issue":
+		token, info, err := client.IssueMemberToken(memberID)
+		if err != nil {
+			return e.fail("Error issuing token", err)
+		}
+		// Only the token goes to stdout, so it can be piped on.
+		e.errorf("Token %d issued. It is only shown now, so hand it over straight away.", info.ID)
+		fmt.Fprintln(e.stdout, token)
+	case "revoke":
+		member, err := client.RevokeMemberToken(memberID, tokenID)
+		if err != nil {
+			return e.fail("Error revoking token", err)
+		}
+		fmt.Fprintf(e.stdout, "Token %d of %s revoked\n", tokenID, member.Name)
+	case "limit":
+		member, err := client.SetMemberLimit(memberID, limit)
+		if err != nil {
+			return e.fail("Error setting spending limit", err)
+		}
+		if member.SpendLimit > 0 {
+			fmt.Fprintf(e.stdout, "%s may spend %.2f\n", member.Name, member.SpendLimit)
+		} else {
+			fmt.Fprintf(e.stdout, "%s has no spending limit\n", member.Name)
+		}
+	case "suspend":
+		member, err := client.SuspendMember(memberID, suspend)
+		if err != nil {
+			return e.fail("Error updating member", err)
+		}
+		if member.Suspended {
+			fmt.Fprintf(e.stdout, "%s is suspended\n", member.Name)
+		} else {
+			fmt.Fprintf(e.stdout, "%s is active again\n", member.Name)
+		}
+	}
+	return exitOK
+}
+
+// printJSON writes v indented, for the --json flags.
+func (e *env) printJSON(v any) int {
+	enc := json.NewEncoder(e.stdout)
+	enc.SetIndent("", "    ")
+	if err := enc.Encode(v); err != nil {
+		return exitError
+	}
+	return exitOK
+}
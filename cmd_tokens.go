@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"synthera/api"
+	"synthera/utils"
+	"text/tabwriter"
+)
+
+// tokensArgs is how many arguments each tokens action takes after its name.
+var tokensArgs = map[string]int{
+	"list":   0,
+	"rotate": 0,
+	"revoke": 1,
+}
+
+func runTokens(e *env, args []string) int {
+	fs := e.flags("tokens")
+	asJSON := fs.Bool("json", false, "print machine-readable JSON")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+	name, rest, code, ok := e.action(fs)
+	if !ok {
+		return code
+	}
+
+	want, known := tokensArgs[name]
+	if !known {
+		e.errorf("Unknown tokens action %q, want list, rotate or revoke", name)
+		return exitUsage
+	}
+	if len(rest) != want {
+		fs.Usage()
+		return exitUsage
+	}
+	var tokenID int
+	if name == "revoke" {
+		id, err := strconv.Atoi(rest[0])
+		if err != nil || id <= 0 {
+			e.errorf("Token ID must be a number, see synthera tokens list")
+			return exitUsage
+		}
+		tokenID = id
+	}
+
+	client, code := e.client()
+	if client == nil {
+		return code
+	}
+
+	switch name {
+	case "list":
+		tokens, err := client.Tokens()
+		if err != nil {
+			return e.fail("Error fetching tokens", err)
+		}
+		if *asJSON {
+			return e.printJSON(tokens)
+		}
+		tw := tabwriter.NewWriter(e.stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintf(tw, "ID\tToken\tCreated\tLast used\t\n")
+		for _, t := range tokens {
+			lastUsed, current := "never", ""
+			if !t.LastUsedAt.IsZero() {
+				lastUsed = t.LastUsedAt.Local().Format("2006-01-02 15:04")
+			}
+			if t.Current {
+				current = "this device"
+			}
+			fmt.Fprintf(tw, "%d\t%s…\t%s\t%s\t%s\n", t.ID, t.Prefix, t.CreatedAt.Local().Format("2006-01-02 15:04"), lastUsed, current)
+		}
+		if err := tw.Flush(); err != nil {
+			return exitError
+		}
+	case "rotate":
+		token, secret, err := client.RotateToken()
+		if err != nil {
+			return e.fail("Error rotating token", err)
+		}
+		if err := utils.SaveCredentials(token, secret); err != nil {
+			// The old token is already revoked, so this is the only copy.
+			e.errorf("Token rotated but could not be saved, store it now: %s", token)
+			if secret != "" {
+				e.errorf("Signing secret: %s", secret)
+			}
+			e.errorf("%v", err)
+			return exitError
+		}
+		fmt.Fprintf(e.stdout, "Token rotated and saved to profile %s, the old one no longer works\n", utils.Profile)
+	case "revoke":
+		tokens, err := client.Tokens()
+		if err != nil {
+			return e.fail("Error fetching tokens", err)
+		}
+		var info *api.TokenInfo
+		for i := range tokens {
+			if tokens[i].ID == tokenID {
+				info = &tokens[i]
+			}
+		}
+		if info == nil {
+			e.errorf("No active token %d, see synthera tokens list", tokenID)
+			return exitError
+		}
+		if !info.Current {
+			if err := client.RevokeToken(tokenID); err != nil {
+				return e.fail("Error revoking token", err)
+			}
+			fmt.Fprintf(e.stdout, "Token %s… revoked\n", info.Prefix)
+			return exitOK
+		}
+		// Revoking this device's token is logging out for good.
+		if err := client.RevokeCurrentToken(); err != nil {
+			return e.fail("Error revoking token", err)
+		}
+		if err := utils.ClearCredentials(); err != nil {
+			e.errorf("Token revoked but can't be removed: %v", err)
+			return exitError
+		}
+		fmt.Fprintf(e.stdout, "Token %s… revoked and removed from profile %s\n", info.Prefix, utils.Profile)
+	}
+	return exitOK
+}
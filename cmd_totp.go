@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"synthera/api"
+)
+
+// confirmAttempts is how many codes enrollment accepts before giving up;
+// a typo shouldn't mean starting over with a new secret.
+const confirmAttempts = 3
+
+func runTOTP(e *env, args []string) int {
+	fs := e.flags("totp")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "enroll" {
+		fs.Usage()
+		return exitUsage
+	}
+
+	client, code := e.client()
+	if client == nil {
+		return code
+	}
+	secret, uri, err := client.EnrollTOTP()
+	if err != nil {
+		return e.fail("Error starting enrollment", err)
+	}
+
+	fmt.Fprintf(e.stdout, "Add this account to your authenticator app. The secret is only shown now.\n\n")
+	fmt.Fprintf(e.stdout, "  Secret: %s\n", groupSecret(secret))
+	fmt.Fprintf(e.stdout, "  Link:   %s\n\n", uri)
+
+	in := bufio.NewReader(os.Stdin)
+	for attempt := 1; ; attempt++ {
+		fmt.Fprint(e.stderr, "Code from the app: ")
+		line, err := readLine(e.ctx, in)
+		if err != nil {
+			e.errorf("\nCan't read code: %v", err)
+			return exitError
+		}
+
+		err = client.ConfirmTOTP(strings.TrimSpace(line))
+		if err == nil {
+			break
+		}
+		if !api.IsInvalidCode(err) || attempt == confirmAttempts {
+			return e.fail("Enrollment failed, run synthera totp enroll to start again", err)
+		}
+		e.errorf("That code was not accepted, check the app and try again")
+	}
+
+	fmt.Fprintln(e.stdout, "Two-step verification is on. Detail lookups will ask for a code from the app.")
+	return exitOK
+}
+
+// groupSecret splits the secret into blocks of four to make it easier to
+// type.
+func groupSecret(secret string) string {
+	var groups []string
+	for len(secret) > 4 {
+		groups = append(groups, secret[:4])
+		secret = secret[4:]
+	}
+	return strings.Join(append(groups, secret), " ")
+}
+
+// readLine reads one line from r, giving up when ctx is cancelled.
+func readLine(ctx context.Context, r *bufio.Reader) (string, error) {
+	type result struct {
+		line string
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		line, err := r.ReadString('\n')
+		if err != nil && line != "" {
+			err = nil
+		}
+		done <- result{line, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.line, r.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"synthera/update"
+	"synthera/version"
+	"time"
+)
+
+func runUpdate(e *env, args []string) int {
+	fs := e.flags("update")
+	check := fs.Bool("check", false, "only report whether a newer release exists")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	u, err := e.updater()
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, 10*time.Minute)
+	defer cancel()
+
+	rel, err := u.Latest(ctx)
+	if err != nil {
+		e.errorf("Error checking for updates: %v", err)
+		return exitError
+	}
+	if !update.Newer(rel.Version, version.Version) {
+		fmt.Fprintf(e.stdout, "synthera %s is up to date\n", version.Version)
+		return exitOK
+	}
+	if *check {
+		fmt.Fprintf(e.stdout, "synthera %s is available (running %s), run synthera update to install it\n", rel.Version, version.Version)
+		return exitOK
+	}
+
+	exe, err := os.Executable()
+	if err == nil {
+		exe, err = filepath.EvalSymlinks(exe)
+	}
+	if err != nil {
+		e.errorf("Can't locate the running binary: %v", err)
+		return exitError
+	}
+
+	fmt.Fprintf(e.stdout, "Downloading %s %s ...\n", rel.Asset, rel.Version)
+	if err := u.Apply(ctx, rel, exe); err != nil {
+		e.errorf("Update failed: %v", err)
+		return exitError
+	}
+	fmt.Fprintf(e.stdout, "Updated to synthera %s\n", rel.Version)
+	return exitOK
+}
+
+// checkForUpdate is the opt-in startup check. It never blocks startup for
+// long and stays quiet on failure, since the user did not ask to update.
+func checkForUpdate(e *env) (string, bool) {
+	if !e.cfg.CheckUpdates || version.Version == "dev" {
+		return "", false
+	}
+	u, err := e.updater()
+	if err != nil {
+		return "", false
+	}
+
+	ctx, cancel := context.WithTimeout(e.ctx, 3*time.Second)
+	defer cancel()
+	rel, err := u.Latest(ctx)
+	if err != nil || !update.Newer(rel.Version, version.Version) {
+		return "", false
+	}
+	return rel.Version, true
+}
+
+// updater checks for and downloads releases over the same transport as
+// the API client, so the network settings apply to both.
+func (e *env) updater() (*update.Updater, error) {
+	transport, err := e.transport()
+	if err != nil {
+		return nil, err
+	}
+	return update.New(e.cfg.ReleaseURL, transport)
+}
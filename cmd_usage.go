@@ -0,0 +1,47 @@
+package main
+
+import (
+	"errors"
+	"synthera/api"
+	"synthera/usage"
+	"time"
+)
+
+func runUsage(e *env, args []string) int {
+	fs := e.flags("usage")
+	format := fs.String("format", string(usage.FormatTable), "output format: table, csv or json")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	f, err := usage.ParseFormat(*format)
+	if err != nil {
+		e.errorf("%v", err)
+		return exitUsage
+	}
+
+	client, code := e.client()
+	if client == nil {
+		return code
+	}
+	user, err := client.Account()
+	if err != nil {
+		return e.fail("Error fetching account", err)
+	}
+	items, err := client.AllHistory()
+	truncated := errors.Is(err, api.ErrHistoryTruncated)
+	if err != nil && !truncated {
+		return e.fail("Error fetching history", err)
+	}
+	if truncated {
+		e.errorf("Warning: %v, the report is partial", err)
+	}
+
+	report := usage.Build(items, user, time.Now())
+	report.Partial = truncated
+	if err := usage.Write(e.stdout, report, f); err != nil {
+		e.errorf("Error writing report: %v", err)
+		return exitError
+	}
+	return exitOK
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+func runVersion(e *env, args []string) int {
+	fs := e.flags("version")
+	asJSON := fs.Bool("json", false, "print machine-readable JSON")
+	if code, ok := e.parse(fs, args); !ok {
+		return code
+	}
+
+	info := e.buildInfo()
+	if *asJSON {
+		enc := json.NewEncoder(e.stdout)
+		enc.SetIndent("", "    ")
+		if err := enc.Encode(info); err != nil {
+			return exitError
+		}
+		return exitOK
+	}
+
+	fmt.Fprintf(e.stdout, "synthera %s\n", info.Short())
+	if info.BuildDate != "" {
+		fmt.Fprintf(e.stdout, "built:    %s\n", info.BuildDate)
+	}
+	fmt.Fprintf(e.stdout, "go:       %s %s/%s\n", info.GoVersion, info.OS, info.Arch)
+	fmt.Fprintf(e.stdout, "backend:  %s\n", info.BaseURL)
+	fmt.Fprintf(e.stdout, "profile:  %s\n", info.Profile)
+	return exitOK
+}
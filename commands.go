@@ -0,0 +1,366 @@
+package main
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"synthera/api"
+	"synthera/rules"
+	"synthera/utils"
+	"synthera/version"
+	"syscall"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Exit codes shared by every subcommand so scripts can branch on them.
+const (
+	exitOK           = 0
+	exitError        = 1
+	exitUsage        = 2
+	exitAuth         = 3
+	exitIncompatible = 4
+	// exitInterrupted follows the shell convention of 128 + SIGINT.
+	exitInterrupted = 130
+)
+
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(e *env, args []string) int
+}
+
+// commands is filled in by init so the help command can list it without
+// an initialisation cycle.
+var commands []*command
+
+func init() {
+	commands = []*command{
+		{"login", "[--stdin] [--signed]", "Save an API token for this profile", runLogin},
+		{"logout", "[--revoke]", "Remove the stored API token", runLogout},
+		{"whoami", "", "Show who the stored token belongs to", runWhoami},
+		{"account", "[--json]", "Show balance and subscriptions", runAccount},
+		{"usage", "[--format table|csv|json]", "Usage and spend report", runUsage},
+		{"team", "list [--json] | issue <member> | revoke <member> <token> | limit <member> <amount> | suspend <member> [on|off]", "Manage team members, their tokens and limits", runTeam},
+		{"tokens", "list [--json] | rotate | revoke <token>", "List, rotate or revoke your tokens", runTokens},
+		{"config", "get [key] | set <key> <value>", "Read or change configuration", runConfig},
+		{"version", "[--json]", "Show version and build information", runVersion},
+		{"totp", "enroll", "Set up two-step verification with an authenticator app", runTOTP},
+		{"doctor", "", "Check configuration and connectivity", runDoctor},
+		{"update", "[--check]", "Install the latest release", runUpdate},
+		{"help", "[command]", "Show help for a command", runHelp},
+	}
+}
+
+func commandNamed(name string) *command {
+	for _, c := range commands {
+		if c.name == name {
+			return c
+		}
+	}
+	panic("unknown command " + name)
+}
+
+type globals struct {
+	profile    string
+	configPath string
+	baseURL    string
+	debug      bool
+	noColor    bool
+}
+
+func (g *globals) register(fs *flag.FlagSet) {
+	fs.StringVar(&g.profile, "profile", g.profile, "credentials profile to use")
+	fs.StringVar(&g.configPath, "config", g.configPath, "path to the config file")
+	fs.StringVar(&g.baseURL, "base-url", g.baseURL, "backend URL")
+	fs.BoolVar(&g.debug, "debug", g.debug, "log requests to stderr")
+	fs.BoolVar(&g.noColor, "no-color", g.noColor, "disable colours")
+}
+
+// env is what a command runs with: parsed global flags, the loaded config
+// for the selected profile and a context cancelled on SIGINT, SIGTERM or
+// SIGHUP.
+type env struct {
+	globals
+	ctx    context.Context
+	cfg    *utils.Config
+	stdout io.Writer
+	stderr io.Writer
+}
+
+func (e *env) errorf(format string, a ...any) {
+	fmt.Fprintf(e.stderr, format+"\n", a...)
+}
+
+// flags returns a FlagSet for a subcommand that also accepts the global
+// flags, so `synthera whoami --profile work` works as well as the reverse.
+func (e *env) flags(name string) *flag.FlagSet {
+	c := commandNamed(name)
+	fs := flag.NewFlagSet(c.name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: synthera %s %s\n\n%s\n\nFlags:\n", c.name, c.args, c.summary)
+		fs.PrintDefaults()
+	}
+	e.globals.register(fs)
+	return fs
+}
+
+// parse parses a subcommand's flags, then loads config using any global
+// flags that came after the command name. When ok is false the command
+// should exit with code straight away.
+func (e *env) parse(fs *flag.FlagSet, args []string) (code int, ok bool) {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK, false
+		}
+		return exitUsage, false
+	}
+	if err := e.setup(); err != nil {
+		e.errorf("%v", err)
+		return exitError, false
+	}
+	return exitOK, true
+}
+
+// action splits the action off a command that takes one, such as team
+// list, and parses the flags that follow it, so they work on either side.
+// When ok is false the command should exit with code straight away.
+func (e *env) action(fs *flag.FlagSet) (name string, args []string, code int, ok bool) {
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return "", nil, exitUsage, false
+	}
+	name = fs.Arg(0)
+	if err := fs.Parse(fs.Args()[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return "", nil, exitOK, false
+		}
+		return "", nil, exitUsage, false
+	}
+	if err := e.setup(); err != nil {
+		e.errorf("%v", err)
+		return "", nil, exitError, false
+	}
+	return name, fs.Args(), exitOK, true
+}
+
+func (e *env) setup() error {
+	if e.noColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+	}
+	if err := utils.SetProfile(e.profile); err != nil {
+		return err
+	}
+	if e.configPath != "" {
+		utils.SetConfigPath(e.configPath)
+	}
+
+	cfg, err := utils.LoadConfig()
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("can't read config: %w", err)
+	}
+	if err != nil {
+		cfg = &utils.Config{}
+	}
+	e.cfg = cfg
+	return nil
+}
+
+func (e *env) transport() (*http.Transport, error) {
+	transport, err := api.NewTransport(api.TransportOptions{
+		Proxy:         e.cfg.Network.Proxy,
+		CABundle:      e.cfg.Network.CABundle,
+		MinTLSVersion: e.cfg.Network.MinTLSVersion,
+		PinnedKeys:    e.cfg.Network.PinnedKeys,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid network config: %w", err)
+	}
+	return transport, nil
+}
+
+func (e *env) clientOptions() ([]api.Option, error) {
+	transport, err := e.transport()
+	if err != nil {
+		return nil, err
+	}
+	opts := []api.Option{api.WithHTTPTransport(transport), api.WithContext(e.ctx)}
+
+	if e.cfg.SigningSecret != "" {
+		secret, err := hex.DecodeString(e.cfg.SigningSecret)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signing secret: %w", err)
+		}
+		opts = append(opts, api.WithSigningSecret(secret))
+	}
+
+	switch {
+	case e.baseURL != "":
+		opts = append(opts, api.WithBaseURL(strings.TrimRight(e.baseURL, "/")))
+	case e.cfg.BaseURL != "":
+		opts = append(opts, api.WithBaseURL(strings.TrimRight(e.cfg.BaseURL, "/")))
+	}
+
+	if e.debug {
+		opts = append(opts, api.WithDebugLog(log.New(e.stderr, "debug: ", log.Ltime)))
+	}
+	return opts, nil
+}
+
+// localRules loads the signed rules file, if one is configured for a
+// backend that serves no rules. Only the key built into the binary can
+// sign it.
+func (e *env) localRules() (r api.Rules, ok bool, err error) {
+	if e.cfg.RulesFile == "" {
+		return api.Rules{}, false, nil
+	}
+	if rules.PublicKey == "" {
+		return api.Rules{}, true, rules.ErrNoPublicKey
+	}
+	key, err := rules.ParsePublicKey(rules.PublicKey)
+	if err != nil {
+		return api.Rules{}, true, err
+	}
+	r, err = rules.Load(e.cfg.RulesFile, key)
+	return r, true, err
+}
+
+// client returns an API client for the stored token, or reports why it
+// can't and the exit code to use.
+func (e *env) client() (*api.Client, int) {
+	if e.cfg.APIToken == "" {
+		e.errorf("Not logged in, run synthera login first")
+		return nil, exitAuth
+	}
+	opts, err := e.clientOptions()
+	if err != nil {
+		e.errorf("%v", err)
+		return nil, exitError
+	}
+	return api.NewClient(e.cfg.APIToken, opts...), exitOK
+}
+
+// fail prints err and maps it to an exit code.
+func (e *env) fail(context string, err error) int {
+	e.errorf("%s: %v", context, err)
+	switch {
+	case api.IsIncompatible(err):
+		e.errorf("This version of synthera is no longer supported, run synthera update")
+		return exitIncompatible
+	case api.IsUnauthorized(err):
+		return exitAuth
+	}
+	return exitError
+}
+
+func (e *env) buildInfo() version.Info {
+	info := version.Read()
+	opts, _ := e.clientOptions()
+	info.BaseURL = api.NewClient("", opts...).BaseURL()
+	info.Profile = utils.Profile
+	return info
+}
+
+// run is the supervisor every command runs under. Signals cancel e.ctx
+// instead of killing the process, so in-flight requests are abandoned and
+// the terminal, config and binary are left as they were; a panic is turned
+// into a crash report.
+func run(args []string) (code int) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	defer stop()
+
+	e := &env{
+		globals: globals{profile: "default"},
+		ctx:     ctx,
+		stdout:  os.Stdout,
+		stderr:  os.Stderr,
+	}
+	// Only the command name goes in the report; arguments can hold tokens
+	// or search terms.
+	where := "startup"
+	defer func() {
+		if r := recover(); r != nil {
+			code = e.reportCrash(recovered(where, r))
+		}
+	}()
+	defer func() {
+		if ctx.Err() != nil && code != exitOK {
+			code = exitInterrupted
+		}
+	}()
+
+	fs := flag.NewFlagSet("synthera", flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() { printUsage(e.stderr) }
+	e.globals.register(fs)
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		if err := e.setup(); err != nil {
+			e.errorf("%v", err)
+			return exitError
+		}
+		where = "the interface"
+		return runTUI(e)
+	}
+
+	name := fs.Arg(0)
+	for _, c := range commands {
+		if c.name == name {
+			where = "command " + name
+			return c.run(e, fs.Args()[1:])
+		}
+	}
+	e.errorf("Unknown command %q", name)
+	printUsage(e.stderr)
+	return exitUsage
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: synthera [global flags] [command] [flags]\n\n")
+	fmt.Fprintf(w, "Without a command the interactive interface starts.\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+
+	fmt.Fprintf(w, "\nGlobal flags:\n")
+	fs := flag.NewFlagSet("synthera", flag.ContinueOnError)
+	fs.SetOutput(w)
+	(&globals{profile: "default"}).register(fs)
+	fs.PrintDefaults()
+
+	fmt.Fprintf(w, "\nExit codes: 0 ok, 1 error, 2 bad usage, 3 not logged in or token rejected, 4 update required, 130 interrupted\n")
+}
+
+func runHelp(e *env, args []string) int {
+	if len(args) == 0 {
+		printUsage(e.stdout)
+		return exitOK
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			// Each command registers its own flags, so let it print them.
+			e.stderr = e.stdout
+			c.run(e, []string{"-h"})
+			return exitOK
+		}
+	}
+	e.errorf("Unknown command %q", args[0])
+	return exitUsage
+}
@@ -0,0 +1,111 @@
+// Package help holds the offline help topics
+// and onboarding pages shipped in the binary
+package help
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+)
+
+//go:embed topics/*.md onboarding/*.md
+var files embed.FS
+
+// AUPVersion is the version of the acceptable-use policy shipped in the
+// binary, used when the backend doesn't publish its own. Bump it whenever
+// aup.md changes so operators accept the new text.
+const AUPVersion = "2026-10"
+
+//go:embed aup.md
+var aup string
+
+// AUP returns the built-in acceptable-use policy.
+func AUP() string {
+	return strings.TrimSpace(aup)
+}
+
+// Topic is one page of help. Files are named NN-id.md, where NN sets the
+// order, and start with a "# Title" line.
+type Topic struct {
+	ID    string
+	Title string
+	Body  string
+}
+
+var (
+	topics     = load("topics")
+	onboarding = load("onboarding")
+)
+
+func load(dir string) []Topic {
+	entries, err := fs.ReadDir(files, dir)
+	if err != nil {
+		panic(err)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	var out []Topic
+	for _, entry := range entries {
+		data, err := files.ReadFile(path.Join(dir, entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		title, body, _ := strings.Cut(string(data), "\n")
+		_, id, _ := strings.Cut(strings.TrimSuffix(entry.Name(), ".md"), "-")
+		out = append(out, Topic{
+			ID:    id,
+			Title: strings.TrimPrefix(title, "# "),
+			Body:  strings.TrimSpace(body),
+		})
+	}
+	return out
+}
+
+func Topics() []Topic {
+	return topics
+}
+
+// Onboarding returns the first-run walkthrough pages in order.
+func Onboarding() []Topic {
+	return onboarding
+}
+
+func Lookup(id string) (Topic, bool) {
+	for _, t := range topics {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return Topic{}, false
+}
+
+// Search returns topics containing every word of query, case-insensitively,
+// with title matches first.
+func Search(query string) []Topic {
+	words := strings.Fields(strings.ToLower(query))
+	var inTitle, inBody []Topic
+	for _, t := range topics {
+		title := strings.ToLower(t.Title)
+		text := title + "\n" + strings.ToLower(t.Body)
+		if !containsAll(text, words) {
+			continue
+		}
+		if containsAll(title, words) {
+			inTitle = append(inTitle, t)
+		} else {
+			inBody = append(inBody, t)
+		}
+	}
+	return append(inTitle, inBody...)
+}
+
+func containsAll(s string, words []string) bool {
+	for _, w := range words {
+		if !strings.Contains(s, w) {
+			return false
+		}
+	}
+	return true
+}
@@ -1,22 +1,40 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"runtime/debug"
+	"synthera/anomaly"
 	"synthera/ui"
-	"synthera/utils"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/term"
 )
 
 func main() {
-	cfg, err := utils.LoadConfig()
-	if err != nil {
-		cfg = &utils.Config{
-			APIToken: "",
+	os.Exit(run(os.Args[1:]))
+}
+
+func runTUI(e *env) int {
+	if e.debug {
+		// stderr belongs to the TUI, so request logs go to a file instead.
+		f, err := tea.LogToFile("synthera-debug.log", "debug")
+		if err != nil {
+			e.errorf("%v", err)
+			return exitError
 		}
+		defer f.Close()
+		e.stderr = f
 	}
-	
+
+	opts, err := e.clientOptions()
+	if err != nil {
+		e.errorf("%v", err)
+		return exitError
+	}
+
 	logo := `
 	
 
@@ -54,10 +72,75 @@ func main() {
 
 	`
 
-	model := ui.InitialModel(cfg.APIToken, logo + "\n\n")
-	p := tea.NewProgram(model)
-	if _, err := p.Run(); err != nil {
+	model := ui.InitialModel(e.cfg.APIToken, logo + "\n\n", opts...)
+	model.BuildInfo = e.buildInfo()
+	model.Mouse = e.cfg.MouseEnabled()
+	model.Onboarded = e.cfg.Onboarded
+	model.AcceptedAUP = e.cfg.AUP
+	model.Watermark = e.cfg.WatermarkEnabled()
+	model.Thresholds = anomaly.Thresholds{
+		Window:        time.Duration(e.cfg.Anomaly.WindowMinutes) * time.Minute,
+		NRICBurst:     e.cfg.Anomaly.NRICBurst,
+		RepeatLookups: e.cfg.Anomaly.RepeatLookups,
+		RelationPages: e.cfg.Anomaly.RelationPages,
+	}
+	model.ReportAnomalies = e.cfg.Anomaly.Report
+	if r, ok, err := e.localRules(); ok {
+		model = model.WithLocalRules(r, err)
+	}
+
+	// The alternate screen keeps records out of the scrollback; mouse
+	// clicks also rely on it to line the view up with screen coordinates.
+	programOpts := []tea.ProgramOption{tea.WithAltScreen()}
+	if model.Mouse {
+		programOpts = append(programOpts, tea.WithMouseCellMotion())
+	}
+	// Signals are handled by run's context rather than Bubble Tea, so one
+	// path quits the program for SIGINT, SIGTERM and a closed terminal,
+	// and the scrubbing below always runs. Panics are caught by the
+	// supervisor for the same reason.
+	programOpts = append(programOpts, tea.WithoutSignalHandler(), tea.WithoutCatchPanics())
+	var p *tea.Program
+	sup := newSupervisor(model, func() { p.Quit() })
+	p = tea.NewProgram(sup, programOpts...)
+	go func() {
+		if latest, ok := checkForUpdate(e); ok {
+			p.Send(ui.UpdateAvailableMsg{Version: latest})
+		}
+	}()
+	go func() {
+		<-e.ctx.Done()
+		p.Quit()
+	}()
+
+	final, err := p.Run()
+	if s, ok := final.(supervisor); ok {
+		final = s.model
+	}
+	if m, ok := final.(ui.MainModel); ok {
+		m.Scrub()
+	}
+	clearTerminal()
+	debug.FreeOSMemory()
+
+	switch {
+	case *sup.crash != nil:
+		return e.reportCrash(*sup.crash)
+	case errors.Is(err, tea.ErrInterrupted) || e.ctx.Err() != nil:
+		return exitInterrupted
+	case err != nil:
 		fmt.Printf("Alas, there's been an error: %v\n", err)
-		os.Exit(1)
+		return exitError
+	}
+	return exitOK
+}
+
+// clearTerminal wipes the screen and, on terminals that support it
+// (xterm, VTE, iTerm2, Windows Terminal), the scrollback, in case a record
+// was drawn outside the alternate screen.
+func clearTerminal() {
+	if !term.IsTerminal(os.Stdout.Fd()) {
+		return
 	}
+	fmt.Fprint(os.Stdout, "\x1b[H\x1b[2J\x1b[3J")
 }
@@ -0,0 +1,179 @@
+// Package rules checks lookups against an
+// organisation's acceptable-use rules
+package rules
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"synthera/api"
+	"time"
+)
+
+// Rule names, shown on the denial screen and recorded in the audit log.
+const (
+	RuleHours  = "Operating hours"
+	RuleDays   = "Operating days"
+	RuleRate   = "Lookup rate"
+	RuleLoaded = "Usage rules"
+)
+
+// PublicKey is the base64 Ed25519 key local rules files must be signed
+// with, set by build.sh through -ldflags "-X synthera/rules.PublicKey=...".
+// Builds without one ignore local rules files.
+var PublicKey = ""
+
+var ErrNoPublicKey = errors.New("this build has no rules signing key, local rules files are not accepted")
+
+var days = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+// Violation is a rule a lookup would break.
+type Violation struct {
+	Rule    string
+	Message string
+}
+
+func (v *Violation) Error() string {
+	return v.Rule + ": " + v.Message
+}
+
+// Check reports the first rule a lookup at now would break, given how many
+// lookups were made in the hour before it. It returns nil when the lookup
+// may go ahead. Rules that fail Validate deny every lookup.
+func Check(r api.Rules, now time.Time, lastHour int) *Violation {
+	if err := Validate(r); err != nil {
+		return &Violation{RuleLoaded, err.Error()}
+	}
+	if r.Timezone != "" {
+		loc, _ := time.LoadLocation(r.Timezone)
+		now = now.In(loc)
+	}
+
+	if len(r.Days) > 0 && !slices.ContainsFunc(r.Days, func(d string) bool {
+		return strings.EqualFold(d, days[now.Weekday()])
+	}) {
+		return &Violation{RuleDays, fmt.Sprintf("Lookups are only allowed on %s, not %s.", strings.Join(r.Days, ", "), now.Weekday())}
+	}
+
+	if r.Hours != "" {
+		start, end, _ := parseHours(r.Hours)
+		minute := now.Hour()*60 + now.Minute()
+		// A range like 22:00-06:00 runs overnight.
+		inside := minute >= start && minute < end
+		if start > end {
+			inside = minute >= start || minute < end
+		}
+		if !inside {
+			return &Violation{RuleHours, fmt.Sprintf("Lookups are only allowed between %s, it is %s.", r.Hours, now.Format("15:04"))}
+		}
+	}
+
+	if r.MaxLookupsPerHour > 0 && lastHour >= r.MaxLookupsPerHour {
+		return &Violation{RuleRate, fmt.Sprintf("You have made %d lookups in the last hour, the limit is %d. Try again later.", lastHour, r.MaxLookupsPerHour)}
+	}
+	return nil
+}
+
+// Validate reports rules that can't be checked, such as a malformed range
+// or an unknown time zone.
+func Validate(r api.Rules) error {
+	if r.Hours != "" {
+		if _, _, err := parseHours(r.Hours); err != nil {
+			return err
+		}
+	}
+	for _, d := range r.Days {
+		if !slices.Contains(days, strings.ToLower(d)) {
+			return fmt.Errorf("unknown day %q, want mon to sun", d)
+		}
+	}
+	if r.Timezone != "" {
+		if _, err := time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("unknown time zone %q", r.Timezone)
+		}
+	}
+	return nil
+}
+
+// parseHours turns "09:00-18:00" into minutes after midnight.
+func parseHours(s string) (start, end int, err error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0, fmt.Errorf("hours %q are not a range like 09:00-18:00", s)
+	}
+	for _, p := range []struct {
+		s   string
+		dst *int
+	}{{from, &start}, {to, &end}} {
+		t, err := time.Parse("15:04", strings.TrimSpace(p.s))
+		if err != nil {
+			return 0, 0, fmt.Errorf("hours %q are not a range like 09:00-18:00", s)
+		}
+		*p.dst = t.Hour()*60 + t.Minute()
+	}
+	return start, end, nil
+}
+
+// signedFile is a local rules file. The signature covers the rules in
+// compact JSON, so reindenting the file doesn't break it.
+type signedFile struct {
+	Rules     json.RawMessage `json:"rules"`
+	Signature string          `json:"signature"`
+}
+
+// ParsePublicKey decodes a base64 Ed25519 public key.
+func ParsePublicKey(s string) (ed25519.PublicKey, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("rules key is not a base64 Ed25519 public key")
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// Load reads a rules file signed by the holder of key's private half,
+// refusing one that has been altered.
+func Load(path string, key ed25519.PublicKey) (api.Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return api.Rules{}, err
+	}
+	var f signedFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return api.Rules{}, fmt.Errorf("rules file %s: %w", path, err)
+	}
+	var signed bytes.Buffer
+	if err := json.Compact(&signed, f.Rules); err != nil {
+		return api.Rules{}, fmt.Errorf("rules file %s: %w", path, err)
+	}
+	sig, err := base64.StdEncoding.DecodeString(f.Signature)
+	if err != nil || !ed25519.Verify(key, signed.Bytes(), sig) {
+		return api.Rules{}, fmt.Errorf("rules file %s is not signed by the configured key", path)
+	}
+
+	var r api.Rules
+	if err := json.Unmarshal(f.Rules, &r); err != nil {
+		return api.Rules{}, fmt.Errorf("rules file %s: %w", path, err)
+	}
+	if err := Validate(r); err != nil {
+		return api.Rules{}, fmt.Errorf("rules file %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Sign produces a rules file Load accepts with key's public half.
+func Sign(r api.Rules, key ed25519.PrivateKey) ([]byte, error) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(signedFile{
+		Rules:     data,
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, data)),
+	}, "", "  ")
+}
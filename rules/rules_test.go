@@ -0,0 +1,127 @@
+package rules
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"synthera/api"
+	"testing"
+	"time"
+)
+
+func TestCheck(t *testing.T) {
+	// A Monday.
+	at := func(hhmm string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", "2026-03-02 "+hhmm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+
+	tests := []struct {
+		name     string
+		rules    api.Rules
+		now      time.Time
+		lastHour int
+		want     string
+	}{
+		{"no rules", api.Rules{}, at("03:00"), 100, ""},
+		{"inside hours", api.Rules{Hours: "09:00-18:00"}, at("09:00"), 0, ""},
+		{"end of hours is exclusive", api.Rules{Hours: "09:00-18:00"}, at("18:00"), 0, RuleHours},
+		{"before hours", api.Rules{Hours: "09:00-18:00"}, at("08:59"), 0, RuleHours},
+		{"overnight, late", api.Rules{Hours: "22:00-06:00"}, at("23:30"), 0, ""},
+		{"overnight, early", api.Rules{Hours: "22:00-06:00"}, at("05:59"), 0, ""},
+		{"overnight, daytime", api.Rules{Hours: "22:00-06:00"}, at("12:00"), 0, RuleHours},
+		{"allowed day", api.Rules{Days: []string{"mon", "TUE"}}, at("12:00"), 0, ""},
+		{"other day", api.Rules{Days: []string{"sat", "sun"}}, at("12:00"), 0, RuleDays},
+		{"under the rate", api.Rules{MaxLookupsPerHour: 3}, at("12:00"), 2, ""},
+		{"at the rate", api.Rules{MaxLookupsPerHour: 3}, at("12:00"), 3, RuleRate},
+		// 12:00 UTC is 20:00 in Kuala Lumpur.
+		{"time zone", api.Rules{Hours: "09:00-18:00", Timezone: "Asia/Kuala_Lumpur"}, at("12:00"), 0, RuleHours},
+		{"malformed hours", api.Rules{Hours: "9 to 5"}, at("12:00"), 0, RuleLoaded},
+		{"unknown day", api.Rules{Days: []string{"someday"}}, at("12:00"), 0, RuleLoaded},
+		{"unknown time zone", api.Rules{Timezone: "Mars/Olympus"}, at("12:00"), 0, RuleLoaded},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if v := Check(tt.rules, tt.now, tt.lastHour); v != nil {
+				got = v.Rule
+			}
+			if got != tt.want {
+				t.Errorf("Check = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := api.Rules{Hours: "09:00-18:00", Days: []string{"mon"}, MaxLookupsPerHour: 5}
+	signed, err := Sign(want, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	t.Run("signed", func(t *testing.T) {
+		got, err := Load(write("signed.json", signed), pub)
+		if err != nil {
+			t.Fatalf("Load: %v", err)
+		}
+		if got.Hours != want.Hours || got.MaxLookupsPerHour != want.MaxLookupsPerHour {
+			t.Errorf("Load = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("reindented", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := json.Indent(&out, signed, "", "\t\t"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(write("reindented.json", out.Bytes()), pub); err != nil {
+			t.Errorf("Load: %v", err)
+		}
+	})
+
+	t.Run("altered", func(t *testing.T) {
+		altered := bytes.Replace(signed, []byte(`18:00`), []byte(`23:00`), 1)
+		if _, err := Load(write("altered.json", altered), pub); err == nil {
+			t.Error("altered rules file was accepted")
+		}
+	})
+
+	t.Run("other key", func(t *testing.T) {
+		other, _, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(write("other.json", signed), other); err == nil {
+			t.Error("rules file accepted with the wrong key")
+		}
+	})
+
+	t.Run("invalid rules", func(t *testing.T) {
+		bad, err := Sign(api.Rules{Hours: "all day"}, priv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Load(write("invalid.json", bad), pub); err == nil {
+			t.Error("signed but invalid rules were accepted")
+		}
+	})
+}
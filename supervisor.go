@@ -0,0 +1,163 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"synthera/ui"
+	"synthera/utils"
+	"synthera/version"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// crash is a recovered panic, reduced to what is safe to write to disk.
+type crash struct {
+	where string
+	cause string
+	stack []byte
+}
+
+// recovered turns a panic value into a crash. The value itself is only
+// kept for runtime errors such as nil dereferences, whose messages are
+// fixed by the runtime; anything else could carry a token or a record.
+func recovered(where string, r any) *crash {
+	cause := fmt.Sprintf("panic of type %T", r)
+	if err, ok := r.(runtime.Error); ok {
+		cause = err.Error()
+	}
+	return &crash{where: where, cause: cause, stack: debug.Stack()}
+}
+
+// writeCrashReport saves c to the state directory and returns the path.
+// Stack traces hold function names and pointer values but no strings, so
+// the report is safe to attach to a support ticket.
+func writeCrashReport(c *crash) (string, error) {
+	dir, err := utils.StateDir()
+	if err != nil {
+		return "", err
+	}
+
+	info := version.Read()
+	var b strings.Builder
+	fmt.Fprintf(&b, "synthera crash report\n\n")
+	fmt.Fprintf(&b, "time:     %s\n", time.Now().UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "version:  %s\n", info.Short())
+	fmt.Fprintf(&b, "go:       %s %s/%s\n", info.GoVersion, info.OS, info.Arch)
+	fmt.Fprintf(&b, "where:    %s\n", c.where)
+	fmt.Fprintf(&b, "cause:    %s\n\n", c.cause)
+	b.Write(c.stack)
+
+	path := filepath.Join(dir, "crash-"+time.Now().Format("20060102-150405")+".txt")
+	if err := os.WriteFile(path, []byte(b.String()), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func (e *env) reportCrash(c *crash) int {
+	path, err := writeCrashReport(c)
+	if err != nil {
+		e.errorf("synthera crashed and could not save a crash report: %v", err)
+		return exitError
+	}
+	e.errorf("synthera crashed, sorry about that. A report was saved to\n\n  %s\n\nIt contains no token or search data; please attach it when you contact support.", path)
+	return exitError
+}
+
+// crashMsg carries a panic recovered in a command back to the event loop.
+type crashMsg struct{ crash *crash }
+
+// supervisor wraps the TUI model so a panic in Update, View or a command
+// ends the program through the normal quit path. That restores the
+// terminal and leaves the model in hand for scrubbing, which Bubble Tea's
+// own recovery does not.
+type supervisor struct {
+	model tea.Model
+	// crash is shared between copies so View, which cannot return a
+	// command, can still record one.
+	crash *(*crash)
+	quit  func()
+}
+
+func newSupervisor(model tea.Model, quit func()) supervisor {
+	return supervisor{model: model, crash: new(*crash), quit: quit}
+}
+
+func (s supervisor) where(in string) string {
+	if m, ok := s.model.(ui.MainModel); ok {
+		return fmt.Sprintf("%s in state %s", in, m.State)
+	}
+	return in
+}
+
+func (s supervisor) Init() (cmd tea.Cmd) {
+	defer func() {
+		if r := recover(); r != nil {
+			*s.crash = recovered(s.where("Init"), r)
+			cmd = tea.Quit
+		}
+	}()
+	return s.guard(s.model.Init())
+}
+
+func (s supervisor) Update(msg tea.Msg) (model tea.Model, cmd tea.Cmd) {
+	if msg, ok := msg.(crashMsg); ok {
+		*s.crash = msg.crash
+		return s, tea.Quit
+	}
+	if *s.crash != nil {
+		return s, tea.Quit
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			*s.crash = recovered(s.where("Update"), r)
+			model, cmd = s, tea.Quit
+		}
+	}()
+	s.model, cmd = s.model.Update(msg)
+	return s, s.guard(cmd)
+}
+
+func (s supervisor) View() (view string) {
+	if *s.crash != nil {
+		return ""
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			*s.crash = recovered(s.where("View"), r)
+			view = ""
+			// View runs inside the event loop, so quitting has to wait
+			// until it returns.
+			go s.quit()
+		}
+	}()
+	return s.model.View()
+}
+
+// guard wraps cmd, and any commands it batches, so a panic in it is
+// reported back as a crashMsg instead of killing the program.
+func (s supervisor) guard(cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() (msg tea.Msg) {
+		defer func() {
+			if r := recover(); r != nil {
+				msg = crashMsg{recovered(s.where("a command"), r)}
+			}
+		}()
+		msg = cmd()
+		if batch, ok := msg.(tea.BatchMsg); ok {
+			for i := range batch {
+				batch[i] = s.guard(batch[i])
+			}
+		}
+		return msg
+	}
+}
@@ -0,0 +1,73 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// The SHA-1 test vectors from RFC 6238 appendix B. The RFC prints eight
+// digits; six-digit codes are their last six.
+var rfc6238 = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+var rfcSecret = []byte("12345678901234567890")
+
+func TestCode(t *testing.T) {
+	for _, v := range rfc6238 {
+		if got := Code(rfcSecret, time.Unix(v.unix, 0)); got != v.code {
+			t.Errorf("Code at %d = %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	for _, v := range rfc6238 {
+		now := time.Unix(v.unix, 0)
+		step, ok := Verify(rfcSecret, v.code, now, 1)
+		if !ok || step != Counter(now) {
+			t.Errorf("Verify(%s) at %d = %d, %v, want step %d", v.code, v.unix, step, ok, Counter(now))
+		}
+	}
+
+	now := time.Unix(1111111111, 0)
+	code := Code(rfcSecret, now)
+	tests := []struct {
+		name string
+		code string
+		at   time.Time
+		skew int
+		want bool
+	}{
+		{"one step late within skew", code, now.Add(Period), 1, true},
+		{"one step early within skew", code, now.Add(-Period), 1, true},
+		{"outside skew", code, now.Add(2 * Period), 1, false},
+		{"no skew", code, now.Add(Period), 0, false},
+		{"surrounding spaces", " " + code + " ", now, 0, true},
+		{"wrong code", "000000", now, 1, false},
+		{"too short", code[:5], now, 1, false},
+	}
+	for _, tt := range tests {
+		if _, ok := Verify(rfcSecret, tt.code, tt.at, tt.skew); ok != tt.want {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	secret := EncodeSecret(rfcSecret)
+	typed := "  " + strings.ToLower(secret[:4]) + " " + secret[4:8] + "-" + secret[8:] + "===="
+	got, err := DecodeSecret(typed)
+	if err != nil || string(got) != string(rfcSecret) {
+		t.Errorf("DecodeSecret(%q) = %q, %v", typed, got, err)
+	}
+}
@@ -0,0 +1,74 @@
+package ui
+
+import (
+	"synthera/anomaly"
+	"synthera/api"
+	"synthera/audit"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// watchAnomalies runs the detector over recent events once a lookup's
+// results are in. Anything unusual is logged, reported if the org or the
+// user asked for it, and shown on a warning screen that has to be acknowledged before
+// the results.
+func (m MainModel) watchAnomalies() (MainModel, tea.Cmd) {
+	if m.User == nil {
+		return m, nil
+	}
+	now := time.Now()
+	t := m.thresholds()
+	findings := anomaly.Detect(m.Recent.Since(now.Add(-t.Window)), m.User.ID, now, t)
+	if len(findings) == 0 {
+		return m, nil
+	}
+
+	var cmds []tea.Cmd
+	for _, f := range findings {
+		m.audit(audit.Event{Action: audit.ActionAnomaly, Kind: f.Kind, RecordID: f.RecordID, Detail: f.Message})
+		if m.reportAnomalies() {
+			cmds = append(cmds, m.ReportAnomaly(f, now))
+		}
+	}
+	m.Anomalies = findings
+	m.AnomalyReturn = m.State
+	m.State = StateAnomaly
+	return m, tea.Batch(cmds...)
+}
+
+// thresholds are the user's, tightened to the org policy's where it sets
+// any.
+func (m MainModel) thresholds() anomaly.Thresholds {
+	t := m.Thresholds.WithDefaults()
+	if m.Policy == nil {
+		return t
+	}
+	return t.Within(policyThresholds(*m.Policy))
+}
+
+func policyThresholds(p api.Policy) anomaly.Thresholds {
+	return anomaly.Thresholds{
+		Window:        time.Duration(p.AnomalyWindowMinutes) * time.Minute,
+		NRICBurst:     p.AnomalyNRICBurst,
+		RepeatLookups: p.AnomalyRepeatLookups,
+		RelationPages: p.AnomalyRelationPages,
+	}
+}
+
+// reportAnomalies is on if either the org policy or the user turned it on;
+// the user can't turn off what the org requires.
+func (m MainModel) reportAnomalies() bool {
+	return m.ReportAnomalies || (m.Policy != nil && m.Policy.ReportAnomalies)
+}
+
+// acknowledge records that the user has read the warnings, which also
+// restarts the count for each pattern, and shows the results.
+func (m MainModel) acknowledge() MainModel {
+	for _, f := range m.Anomalies {
+		m.audit(audit.Event{Action: audit.ActionAnomalyAck, Kind: f.Kind, RecordID: f.RecordID})
+	}
+	m.Anomalies = nil
+	m.State = m.AnomalyReturn
+	return m
+}
@@ -0,0 +1,65 @@
+package ui
+
+import (
+	"synthera/api"
+	"synthera/audit"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// approvalsRequired reports whether full lookups need a supervisor's
+// sign-off first. The backend enforces this too; the client only uses it
+// to ask for the justification up front instead of failing the lookup.
+func (m MainModel) approvalsRequired() bool {
+	return m.Policy != nil && m.Policy.ApprovalsRequired
+}
+
+// requestApproval asks for the justification to send with req.
+func (m MainModel) requestApproval(req api.SubmitApprovalRequest) MainModel {
+	m.PendingLookup = &req
+	m.JustificationInput.Reset()
+	m.JustificationInput.Focus()
+	m.State = StateJustification
+	return m
+}
+
+// pageRelations steps delta pages through the relationships of the record
+// opened, which is a lookup of its own and so, under an approvals policy,
+// needs its own sign-off.
+func (m MainModel) pageRelations(delta int) (MainModel, tea.Cmd) {
+	if m.approvalsRequired() {
+		return m.requestApproval(api.SubmitApprovalRequest{
+			Kind:     api.ApprovalTraceRelations,
+			RecordID: m.UserID,
+			Offset:   m.Offset + delta,
+		}), nil
+	}
+	return m.lookup(m.FetchRelations(m.UserID, m.Offset+delta), audit.LookupRelations)
+}
+
+// newApprovalList puts requests waiting on the user first, then their own,
+// newest first as the backend returns them.
+func (m MainModel) newApprovalList(mine, pending []api.Approval) list.Model {
+	var items []list.Item
+	withheld := m.withholds("mykad")
+	for _, a := range pending {
+		items = append(items, approvalItem{approval: a, withheld: withheld})
+	}
+	for _, a := range mine {
+		items = append(items, approvalItem{approval: a, mine: true, withheld: withheld})
+	}
+
+	approvalKeys := newApprovalKeyMap()
+	l := newList(items, m.Width, m.listHeight())
+	l.Title = "Approvals"
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		bindings := []key.Binding{approvalKeys.run, approvalKeys.refresh}
+		if len(pending) > 0 {
+			bindings = append(bindings, approvalKeys.approve, approvalKeys.reject)
+		}
+		return bindings
+	}
+	return l
+}
@@ -0,0 +1,113 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"synthera/api"
+	"synthera/audit"
+	"synthera/help"
+	"synthera/utils"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// aupAccepted reports whether the user has accepted the policy in force.
+// A backend that tracks acceptance is the only word on it; the record kept
+// on this machine, which the user can edit, only counts for one that
+// doesn't.
+func (m MainModel) aupAccepted() bool {
+	switch {
+	case m.AUP == nil || m.User == nil:
+		return false
+	case !m.AUPLocal:
+		return m.AUP.AcceptedVersion == m.AUP.Version
+	}
+	return slices.ContainsFunc(m.AcceptedAUP, func(a utils.AUPAcceptance) bool {
+		return a.UserID == m.User.ID && a.Version == m.AUP.Version
+	})
+}
+
+// gateAUP opens the policy from the main menu while it still needs
+// accepting, so it is the first thing a new operator, or one facing a new
+// version, sees.
+func (m MainModel) gateAUP() MainModel {
+	if m.State == StateMainMenu && m.AUP != nil && m.User != nil && !m.aupAccepted() {
+		return m.openAUP()
+	}
+	return m
+}
+
+// lockedAUP is where tracing leads until the policy is accepted: the policy
+// itself, or why it couldn't be loaded.
+func (m MainModel) lockedAUP() (MainModel, tea.Cmd) {
+	if m.AUP == nil {
+		m.State = StateError
+		m.ErrorMessage = "Tracing is locked until you accept the acceptable-use policy, which hasn't loaded yet. Try again in a moment."
+		if m.AUPErr != nil {
+			m.ErrorMessage = fmt.Sprintf("Tracing is locked until you accept the acceptable-use policy, which could not be loaded: %s", m.AUPErr.Error())
+		}
+		return m, m.FetchAUP()
+	}
+	return m.openAUP(), nil
+}
+
+func (m MainModel) openAUP() MainModel {
+	m.State = StateAUP
+	m = m.refreshAUP()
+	m.AUPView.GotoTop()
+	return m
+}
+
+// refreshAUP re-renders the policy for the current width.
+func (m MainModel) refreshAUP() MainModel {
+	if m.AUP == nil {
+		return m
+	}
+	content := renderTopic(help.Topic{
+		Title: fmt.Sprintf("Acceptable use policy, version %s", m.AUP.Version),
+		Body:  m.AUP.Text,
+	}, m.textWidth())
+	m.AUPView.Width = m.textWidth()
+	m.AUPView.Height = max(3, min(lipgloss.Height(content), m.Height-height(m.aupHelp())-height(m.buttonBar())))
+	m.AUPView.SetContent(content)
+	return m
+}
+
+func (m MainModel) aupHelp() string {
+	scroll := ""
+	if !m.AUPView.AtTop() || !m.AUPView.AtBottom() {
+		scroll = fmt.Sprintf("[↑/↓] scroll (%.0f%%) · ", m.AUPView.ScrollPercent()*100)
+	}
+	if m.aupAccepted() {
+		return m.help("\n" + scroll + "You have accepted this version. Press esc to go back")
+	}
+	return m.help("\n" + scroll + "Press a to accept, esc to go back. Tracing stays locked until you accept")
+}
+
+// recordAUP keeps an acceptance on this machine and in the audit log once
+// the backend has it.
+func (m MainModel) recordAUP(a api.AUPAcceptance) MainModel {
+	local := utils.AUPAcceptance{
+		UserID:     a.UserID,
+		Version:    a.Version,
+		AcceptedAt: a.AcceptedAt,
+	}
+	m.AcceptedAUP = append(m.AcceptedAUP, local)
+	if m.AUP != nil {
+		aup := *m.AUP
+		aup.AcceptedVersion = a.Version
+		m.AUP = &aup
+	}
+	m.audit(audit.Event{Action: audit.ActionAUPAccept, Detail: a.Version})
+	m.State = StateMainMenu
+	err := utils.UpdateConfig(func(cfg *utils.Config) error {
+		cfg.AUP = append(cfg.AUP, local)
+		return nil
+	})
+	if err != nil {
+		m.State = StateError
+		m.ErrorMessage = fmt.Sprintf("Your acceptance was recorded by the server but could not be saved here: %s", err.Error())
+	}
+	return m
+}
@@ -0,0 +1,26 @@
+package ui
+
+import (
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// confirm asks before running next, an action that can't be undone. Only
+// y goes ahead; any other key returns to the current screen as it was.
+func (m MainModel) confirm(prompt string, next tea.Cmd) MainModel {
+	m.ConfirmPrompt = prompt
+	m.ConfirmNext = next
+	m.ConfirmReturn = m.State
+	m.State = StateConfirm
+	return m
+}
+
+func (m MainModel) updateConfirm(msg tea.KeyMsg) (MainModel, tea.Cmd) {
+	next := m.ConfirmNext
+	m.ConfirmNext = nil
+	if msg.String() == "y" || msg.String() == "Y" {
+		m.State = StateLoading
+		return m, next
+	}
+	m.State = m.ConfirmReturn
+	return m, nil
+}
@@ -0,0 +1,52 @@
+package ui
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"synthera/api"
+)
+
+// records returns the client for record lookups, withholding whatever the
+// field policy hides from the user's role. If the policy hasn't loaded yet
+// it is fetched here, so a lookup never runs without one.
+func (m MainModel) records() (*api.Client, error) {
+	if m.FieldPolicy != nil {
+		return m.APIClient.Withholding(m.FieldPolicy.Hidden), nil
+	}
+	if m.User == nil {
+		return nil, errors.New("your account hasn't loaded yet, try again")
+	}
+	policy, err := m.APIClient.FieldPolicy(m.User.Role)
+	if err != nil && !api.IsNotFound(err) {
+		return nil, err
+	}
+	return m.APIClient.Withholding(policy.Hidden), nil
+}
+
+// withholds reports whether the field policy hides the record field name
+// from the user's role.
+func (m MainModel) withholds(name string) bool {
+	return m.FieldPolicy != nil && slices.Contains(m.FieldPolicy.Hidden, name)
+}
+
+// withheldNote names the fields the policy kept out of the record shown.
+func (m MainModel) withheldNote() string {
+	if len(m.Withheld) == 0 {
+		return ""
+	}
+	labels := make([]string, len(m.Withheld))
+	for i, name := range m.Withheld {
+		labels[i] = fieldLabel(name)
+	}
+	return m.help("\nWithheld by your role's policy: " + strings.Join(labels, ", "))
+}
+
+// fieldLabel turns a record field's JSON name into the label the details
+// box uses for it.
+func fieldLabel(name string) string {
+	if name == "" {
+		return name
+	}
+	return strings.ToUpper(name[:1]) + name[1:]
+}
@@ -0,0 +1,170 @@
+package ui
+
+import (
+	"fmt"
+	"strings"
+	"synthera/help"
+	"synthera/utils"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// contextTopics picks the help topic ? opens on each screen.
+var contextTopics = map[AppState]string{
+	StateTokenInput:       "getting-started",
+	StateMainMenu:         "keys",
+	StateTraceNameInput:   "tracing",
+	StateTraceNameResults: "tracing",
+	StateTraceNRICInput:   "tracing",
+	StateTraceDetails:     "tracing",
+	StateHistory:          "history",
+	StateUsage:            "costs",
+	StateTeam:             "team",
+	StateTeamTokens:       "team",
+	StateTeamLimitInput:   "team",
+	StateTeamNewToken:     "team",
+	StateTokens:           "tokens",
+	StateJustification:    "approvals",
+	StateApprovals:        "approvals",
+	StateStepUp:           "two-step",
+	StateDenied:           "privacy",
+	StateAnomaly:          "privacy",
+	StateAUP:              "privacy",
+	StateReport:           "tracing",
+	StateReports:          "tracing",
+	StateError:            "troubleshooting",
+	StateUpdateRequired:   "troubleshooting",
+	StateAbout:            "troubleshooting",
+}
+
+// wantsContextHelp reports whether msg should open help for the current
+// screen. F1 always does; ? only where it can't be meant as text.
+func (m MainModel) wantsContextHelp(msg tea.KeyMsg) bool {
+	switch {
+	case msg.Type == tea.KeyF1:
+	case msg.String() == "?" && !m.typing():
+	default:
+		return false
+	}
+	_, ok := contextTopics[m.State]
+	return ok
+}
+
+// typing reports whether keys go into a text input or a list filter. The
+// step-up code takes digits only, so ? stays free there.
+func (m MainModel) typing() bool {
+	switch m.State {
+	case StateTokenInput, StateTraceNameInput, StateTraceIDInput, StateTraceNRICInput,
+		StateTeamLimitInput, StateJustification, StateReport:
+		return true
+	}
+	return m.List.FilterState() == list.Filtering || m.Menu.FilterState() == list.Filtering
+}
+
+// showTopic opens a help topic; leaving it goes back to returnTo.
+func (m MainModel) showTopic(id string, returnTo AppState) MainModel {
+	m.HelpTopic = id
+	m.HelpReturn = returnTo
+	m.State = StateHelpTopic
+	m = m.refreshHelp()
+	m.HelpView.GotoTop()
+	return m
+}
+
+func (m MainModel) openHelpSearch() MainModel {
+	m.HelpInput.Reset()
+	m.HelpInput.Focus()
+	m.HelpList.SetItems(helpItems(help.Topics()))
+	m.HelpList.Select(0)
+	m.State = StateHelp
+	return m
+}
+
+func (m MainModel) searchHelp() MainModel {
+	topics := help.Topics()
+	if q := strings.TrimSpace(m.HelpInput.Value()); q != "" {
+		topics = help.Search(q)
+	}
+	m.HelpList.SetItems(helpItems(topics))
+	m.HelpList.Select(0)
+	return m
+}
+
+func helpItems(topics []help.Topic) []list.Item {
+	items := make([]list.Item, len(topics))
+	for i, t := range topics {
+		items[i] = helpItem{topic: t}
+	}
+	return items
+}
+
+// refreshHelp re-renders the open topic for the current width.
+func (m MainModel) refreshHelp() MainModel {
+	topic, ok := help.Lookup(m.HelpTopic)
+	if !ok {
+		return m
+	}
+	content := renderTopic(topic, m.textWidth())
+	m.HelpView.Width = m.textWidth()
+	m.HelpView.Height = max(3, min(lipgloss.Height(content), m.Height-height(m.topicHelp())-height(m.buttonBar())))
+	m.HelpView.SetContent(content)
+	return m
+}
+
+func (m MainModel) topicHelp() string {
+	if m.HelpView.AtTop() && m.HelpView.AtBottom() {
+		return m.help("\nPress any key to go back")
+	}
+	return m.help(fmt.Sprintf("\n[↑/↓] scroll (%.0f%%), any other key goes back", m.HelpView.ScrollPercent()*100))
+}
+
+// finishOnboarding records that the walkthrough was seen so it is only
+// shown after the first login.
+func (m MainModel) finishOnboarding() MainModel {
+	m.Onboarded = true
+	m.State = StateMainMenu
+	err := utils.UpdateConfig(func(cfg *utils.Config) error {
+		cfg.Onboarded = true
+		return nil
+	})
+	if err != nil {
+		m.State = StateError
+		m.ErrorMessage = fmt.Sprintf("Could not save settings, the walkthrough will show again next time: %s", err.Error())
+	}
+	return m.gateAUP()
+}
+
+func (m MainModel) onboardingView() string {
+	pages := help.Onboarding()
+	page := pages[m.OnboardingPage]
+	inner := m.textWidth() - m.boxStyle().GetHorizontalFrameSize()
+
+	next := "enter next"
+	if m.OnboardingPage == len(pages)-1 {
+		next = "enter to start"
+	}
+	return m.box(renderTopic(page, inner)) +
+		m.help(fmt.Sprintf("\nPage %d of %d · %s · p back · esc skip", m.OnboardingPage+1, len(pages), next))
+}
+
+// renderTopic wraps a topic's body to width. Paragraphs are separated by
+// blank lines and lines starting with "- " become bullets.
+func renderTopic(t help.Topic, width int) string {
+	blocks := []string{labelStyle.Render(t.Title), ""}
+	for _, line := range strings.Split(t.Body, "\n") {
+		switch {
+		case line == "":
+			blocks = append(blocks, "")
+		case strings.HasPrefix(line, "- "):
+			blocks = append(blocks, lipgloss.JoinHorizontal(lipgloss.Top,
+				"• ",
+				valueStyle.Width(width-2).Render(strings.TrimPrefix(line, "- ")),
+			))
+		default:
+			blocks = append(blocks, valueStyle.Width(width).Render(line))
+		}
+	}
+	return strings.Join(blocks, "\n")
+}
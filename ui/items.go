@@ -1,16 +1,31 @@
 package ui
 
-import "github.com/charmbracelet/bubbles/key"
+import (
+	"fmt"
+	"strings"
+	"synthera/api"
+
+	"github.com/charmbracelet/bubbles/key"
+)
 
 func (i nameItem) Title() string {
 	return i.item.Name
 }
 
 func (i nameItem) Description() string {
+	if i.withheld {
+		return "Mykad withheld"
+	}
+	if i.masked {
+		return maskMykad(i.item.Mykad)
+	}
 	return i.item.Mykad
 }
 
 func (i nameItem) FilterValue() string {
+	if i.masked || i.withheld {
+		return i.item.Name
+	}
 	return i.item.Mykad
 }
 
@@ -28,6 +43,25 @@ func newListKeyMap() *listKeyMap {
 			key.WithHelp("m", "back to menu"),
 			key.WithKeys("m"),
 		),
+		reveal: key.NewBinding(
+			key.WithHelp("v", "reveal mykad"),
+			key.WithKeys("v"),
+		),
+	}
+}
+
+// nameListKeys is the extra key help for name results.
+func nameListKeys(masked bool) func() []key.Binding {
+	listKeys := newListKeyMap()
+	return func() []key.Binding {
+		bindings := []key.Binding{
+			listKeys.toggleNextPage,
+			listKeys.togglePreviousPage,
+		}
+		if masked {
+			bindings = append(bindings, listKeys.reveal)
+		}
+		return bindings
 	}
 }
 
@@ -35,6 +69,15 @@ func (i menuItem) Title() string       { return i.Name }
 func (i menuItem) Description() string { return i.Desc }
 func (i menuItem) FilterValue() string { return i.Name }
 
+func (i helpItem) Title() string { return i.topic.Title }
+
+func (i helpItem) Description() string {
+	first, _, _ := strings.Cut(i.topic.Body, "\n")
+	return first
+}
+
+func (i helpItem) FilterValue() string { return i.topic.Title }
+
 func (i historyItem) Title() string {
 	return i.item.Query
 }
@@ -46,3 +89,175 @@ func (i historyItem) Description() string {
 func (i historyItem) FilterValue() string {
 	return i.item.Query
 }
+
+func (i memberItem) Title() string {
+	if i.member.Suspended {
+		return i.member.Name + " (suspended)"
+	}
+	return i.member.Name
+}
+
+func (i memberItem) Description() string {
+	parts := []string{i.member.Email, i.member.Role}
+	if i.member.SpendLimit > 0 {
+		parts = append(parts, fmt.Sprintf("spent %.2f of %.2f", i.member.Spent, i.member.SpendLimit))
+	} else {
+		parts = append(parts, fmt.Sprintf("spent %.2f, no limit", i.member.Spent))
+	}
+	parts = append(parts, fmt.Sprintf("%d tokens", len(i.member.Tokens)))
+	return strings.Join(parts, " · ")
+}
+
+func (i memberItem) FilterValue() string {
+	return i.member.Name + " " + i.member.Email
+}
+
+func (i tokenItem) Title() string {
+	if i.info.Current {
+		return i.info.Prefix + "… (this device)"
+	}
+	return i.info.Prefix + "…"
+}
+
+func (i tokenItem) Description() string {
+	lastUsed := "never used"
+	if !i.info.LastUsedAt.IsZero() {
+		lastUsed = "last used " + i.info.LastUsedAt.Local().Format("2006-01-02 15:04")
+	}
+	return "Created " + i.info.CreatedAt.Local().Format("2006-01-02 15:04") + " · " + lastUsed
+}
+
+func (i tokenItem) FilterValue() string {
+	return i.info.Prefix
+}
+
+// target names what the approval is for.
+func (i approvalItem) target() string {
+	switch i.approval.Kind {
+	case api.ApprovalTraceNRIC:
+		if i.withheld {
+			return "Mykad search"
+		}
+		return "Mykad " + i.approval.NRIC
+	case api.ApprovalTraceRelations:
+		return fmt.Sprintf("Relationships of record %d, page %d", i.approval.RecordID, i.approval.Offset)
+	}
+	return fmt.Sprintf("Record %d", i.approval.RecordID)
+}
+
+func (i approvalItem) Title() string {
+	target := i.target()
+	if !i.mine {
+		return fmt.Sprintf("%s: %s (needs your decision)", i.approval.RequestedBy, target)
+	}
+	return fmt.Sprintf("%s (%s)", target, i.approval.Status)
+}
+
+func (i approvalItem) Description() string {
+	parts := []string{"\"" + i.approval.Justification + "\"", i.approval.CreatedAt.Local().Format("2006-01-02 15:04")}
+	switch i.approval.Status {
+	case api.ApprovalApproved:
+		parts = append(parts, "approved by "+i.approval.DecidedBy+", enter to run")
+	case api.ApprovalRejected:
+		parts = append(parts, "rejected by "+i.approval.DecidedBy)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (i approvalItem) FilterValue() string {
+	if i.withheld {
+		return i.approval.RequestedBy + " " + i.approval.Justification
+	}
+	return i.approval.RequestedBy + " " + i.approval.NRIC + " " + i.approval.Justification
+}
+
+func newApprovalKeyMap() *approvalKeyMap {
+	return &approvalKeyMap{
+		run: key.NewBinding(
+			key.WithHelp("enter", "run approved"),
+			key.WithKeys("enter"),
+		),
+		approve: key.NewBinding(
+			key.WithHelp("a", "approve"),
+			key.WithKeys("a"),
+		),
+		reject: key.NewBinding(
+			key.WithHelp("x", "reject"),
+			key.WithKeys("x"),
+		),
+		refresh: key.NewBinding(
+			key.WithHelp("r", "refresh"),
+			key.WithKeys("r"),
+		),
+	}
+}
+
+func (i reportItem) Title() string {
+	labels := make([]string, len(i.report.Fields))
+	for n, name := range i.report.Fields {
+		labels[n] = fieldLabel(name)
+	}
+	return fmt.Sprintf("Record %d: %s (%s)", i.report.RecordID, strings.Join(labels, ", "), i.report.Status)
+}
+
+func (i reportItem) Description() string {
+	parts := []string{"\"" + i.report.Comment + "\"", i.report.CreatedAt.Local().Format("2006-01-02 15:04")}
+	if i.report.Status != api.ReportOpen {
+		parts = append(parts, i.report.Status+" "+i.report.UpdatedAt.Local().Format("2006-01-02"))
+	}
+	if i.report.Resolution != "" {
+		parts = append(parts, i.report.Resolution)
+	}
+	return strings.Join(parts, " · ")
+}
+
+func (i reportItem) FilterValue() string {
+	return fmt.Sprintf("%d %s %s", i.report.RecordID, strings.Join(i.report.Fields, " "), i.report.Comment)
+}
+
+func newReportKeyMap() *reportKeyMap {
+	return &reportKeyMap{
+		refresh: key.NewBinding(
+			key.WithHelp("r", "refresh"),
+			key.WithKeys("r"),
+		),
+	}
+}
+
+func newTeamKeyMap() *teamKeyMap {
+	return &teamKeyMap{
+		issueToken: key.NewBinding(
+			key.WithHelp("t", "issue token"),
+			key.WithKeys("t"),
+		),
+		revokeToken: key.NewBinding(
+			key.WithHelp("r", "revoke token"),
+			key.WithKeys("r"),
+		),
+		setLimit: key.NewBinding(
+			key.WithHelp("l", "spending limit"),
+			key.WithKeys("l"),
+		),
+		toggleSuspend: key.NewBinding(
+			key.WithHelp("s", "suspend/resume"),
+			key.WithKeys("s"),
+		),
+	}
+}
+
+func newTokenKeyMap() *tokenKeyMap {
+	return &tokenKeyMap{
+		rotate: key.NewBinding(
+			key.WithHelp("r", "rotate"),
+			key.WithKeys("r"),
+		),
+		revoke: key.NewBinding(
+			key.WithHelp("d", "revoke selected"),
+			key.WithKeys("d"),
+		),
+		revokeDevice: key.NewBinding(
+			key.WithHelp("x", "revoke this device"),
+			key.WithKeys("x"),
+		),
+	}
+}
@@ -0,0 +1,195 @@
+package ui
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// layout is picked from the terminal size on every tea.WindowSizeMsg.
+type layout int
+
+const (
+	layoutCompact layout = iota
+	layoutNormal
+	layoutWide
+)
+
+const (
+	compactMaxWidth  = 60
+	compactMaxHeight = 20
+	wideMinWidth     = 120
+
+	// logoMinContent is the room left under the logo for the input and
+	// help text; below that the logo is dropped.
+	logoMinContent = 8
+)
+
+func layoutFor(width, height int) layout {
+	switch {
+	case width < compactMaxWidth || height < compactMaxHeight:
+		return layoutCompact
+	case width >= wideMinWidth:
+		return layoutWide
+	}
+	return layoutNormal
+}
+
+// resize applies a new terminal size to every component, not just the one
+// on screen, so switching state never shows something laid out for an old
+// size.
+func (m MainModel) resize(width, height int) MainModel {
+	h, v := m.Doc.GetFrameSize()
+	m.Width = width - h
+	m.Height = height - v
+	m.Layout = layoutFor(width, height)
+
+	m.List.SetSize(m.Width, m.listHeight())
+	m.Menu.SetSize(m.Width, m.Height)
+	// The search input and a blank line sit above the help list, and its
+	// key help below.
+	m.HelpList.SetSize(m.Width, m.listHeight()-4)
+
+	// Leave room for the prompt and cursor.
+	inputWidth := min(50, m.textWidth()-4)
+	for _, input := range []*textinput.Model{&m.TokenInput, &m.NameInput, &m.NRICInput, &m.LimitInput, &m.JustificationInput, &m.ReportInput, &m.StepUpInput, &m.HelpInput} {
+		input.Width = inputWidth
+	}
+	m = m.refreshHelp()
+	m = m.refreshAUP()
+	return m.refreshDetails()
+}
+
+// textWidth is the width help, error and detail text wraps at.
+func (m MainModel) textWidth() int {
+	switch m.Layout {
+	case layoutCompact:
+		return max(m.Width, 20)
+	case layoutWide:
+		return min(m.Width, 100)
+	}
+	return min(m.Width, 60)
+}
+
+// logo returns the banner unless it would push the content off screen.
+func (m MainModel) logo() string {
+	if m.Layout == layoutCompact || m.Height < lipgloss.Height(m.Logo)+logoMinContent {
+		return ""
+	}
+	return m.Logo
+}
+
+// help renders key help under a view. Help usually starts with a newline
+// to leave a gap; that is kept outside the styled block, or the padded
+// blank line would join onto the view's last line and widen it.
+func (m MainModel) help(text string) string {
+	if rest, ok := strings.CutPrefix(text, "\n"); ok {
+		return "\n" + helpStyle.Width(m.textWidth()).Render(rest)
+	}
+	return helpStyle.Width(m.textWidth()).Render(text)
+}
+
+func (m MainModel) errorText(text string) string {
+	style := errorStyle.Width(m.textWidth())
+	if m.Layout == layoutCompact {
+		style = style.PaddingBottom(1)
+	}
+	return style.Render(text)
+}
+
+func (m MainModel) boxStyle() lipgloss.Style {
+	if m.Layout == layoutCompact {
+		return boxStyle.Margin(0).Padding(0, 1)
+	}
+	return boxStyle
+}
+
+func (m MainModel) box(content string) string {
+	return m.boxStyle().Render(content)
+}
+
+// detailsHelp is the key help under the details box.
+func (m MainModel) detailsHelp() string {
+	text := "\nPress [n] to view next relationships, [p] for previous, [r] to report an inaccuracy, and any other key to return to main menu."
+	if !m.Details.AtTop() || !m.Details.AtBottom() {
+		text = fmt.Sprintf("\n[↑/↓] scroll (%.0f%%), [n] next relationships, [p] previous, [r] report an inaccuracy, any other key returns to main menu.", m.Details.ScrollPercent()*100)
+	}
+	if m.ReportNotice != "" {
+		text = "\n" + m.ReportNotice + text
+	}
+	return m.help(text)
+}
+
+// refreshDetails re-renders the details box into its viewport. It runs
+// whenever the record or the terminal size changes; the viewport only
+// scrolls when the box is taller than the space left on screen.
+func (m MainModel) refreshDetails() MainModel {
+	if m.UserDetails == nil {
+		return m
+	}
+	details := m.UserDetails
+
+	fields := []field{
+		{"Name", details.Name},
+		{"Mykad", details.Mykad},
+		{"Address", details.Address},
+		{"Gender", details.Gender},
+		{"Mobile", details.Mobile},
+		{"Phone", details.Phone},
+		{"Race", details.Race},
+		{"Religion", details.Religion},
+		{"Income", details.Income},
+	}
+	if m.Relations != nil {
+		fields = append(fields, field{"Relation", m.Relations.Relation})
+	}
+	fields = slices.DeleteFunc(fields, func(f field) bool { return f.Value == "" })
+
+	inner := m.textWidth() - m.boxStyle().GetHorizontalFrameSize()
+	var body string
+	if m.Layout == layoutWide {
+		// Two columns; the gap is taken out of the space the values wrap in.
+		left, right := fields[:len(fields)/2+len(fields)%2], fields[len(fields)/2+len(fields)%2:]
+		colWidth := (inner - 3) / 2
+		body = lipgloss.JoinHorizontal(lipgloss.Top, fieldRows(left, colWidth), "   ", fieldRows(right, colWidth))
+	} else {
+		body = fieldRows(fields, inner)
+	}
+	content := m.box(body) + m.withheldNote()
+
+	chrome := height(m.logo()) + height(m.detailsHelp()) + height(m.buttonBar())
+	m.Details.Width = lipgloss.Width(content)
+	m.Details.Height = max(3, min(lipgloss.Height(content), m.Height-chrome))
+	m.Details.SetContent(content)
+	return m
+}
+
+// fieldRows renders label: value rows with values wrapped to fit width and
+// indented under the first line.
+func fieldRows(fields []field, width int) string {
+	labelWidth := 0
+	for _, f := range fields {
+		labelWidth = max(labelWidth, lipgloss.Width(f.Label)+2)
+	}
+	valueWidth := max(width-labelWidth, 10)
+
+	var rows []string
+	for _, f := range fields {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top,
+			labelStyle.Width(labelWidth).Render(f.Label+":"),
+			valueStyle.Width(valueWidth).Render(f.Value),
+		))
+	}
+	return strings.Join(rows, "\n")
+}
+
+// height is lipgloss.Height, except that nothing takes up no lines.
+func height(s string) int {
+	if s == "" {
+		return 0
+	}
+	return lipgloss.Height(s)
+}
@@ -2,14 +2,25 @@
 package ui
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"synthera/anomaly"
 	"synthera/api"
+	"synthera/audit"
+	"synthera/help"
+	"synthera/usage"
 	"synthera/utils"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
@@ -27,9 +38,69 @@ const (
 	StateMainMenu
 	StateTraceNRICInput
 	StateHistory
+	StateUsage
+	StateTeam
+	StateTeamTokens
+	StateTeamLimitInput
+	StateTeamNewToken
+	StateTokens
+	StateUpdateRequired
+	StateAbout
+	StateHelp
+	StateHelpTopic
+	StateOnboarding
+	StateJustification
+	StateApprovals
+	StateStepUp
+	StateDenied
+	StateAnomaly
+	StateAUP
+	StateReport
+	StateReports
+	StateConfirm
 )
 
-func InitialModel(initialToken string, logo string) MainModel {
+var stateNames = [...]string{
+	StateTokenInput:       "TokenInput",
+	StateTraceNameInput:   "TraceNameInput",
+	StateTraceNameResults: "TraceNameResults",
+	StateTraceIDInput:     "TraceIDInput",
+	StateTraceDetails:     "TraceDetails",
+	StateError:            "Error",
+	StateLoading:          "Loading",
+	StateMainMenu:         "MainMenu",
+	StateTraceNRICInput:   "TraceNRICInput",
+	StateHistory:          "History",
+	StateUsage:            "Usage",
+	StateTeam:             "Team",
+	StateTeamTokens:       "TeamTokens",
+	StateTeamLimitInput:   "TeamLimitInput",
+	StateTeamNewToken:     "TeamNewToken",
+	StateTokens:           "Tokens",
+	StateUpdateRequired:   "UpdateRequired",
+	StateAbout:            "About",
+	StateHelp:             "Help",
+	StateHelpTopic:        "HelpTopic",
+	StateOnboarding:       "Onboarding",
+	StateJustification:    "Justification",
+	StateApprovals:        "Approvals",
+	StateStepUp:           "StepUp",
+	StateDenied:           "Denied",
+	StateAnomaly:          "Anomaly",
+	StateAUP:              "AUP",
+	StateReport:           "Report",
+	StateReports:          "Reports",
+	StateConfirm:          "Confirm",
+}
+
+func (s AppState) String() string {
+	if s >= 0 && int(s) < len(stateNames) {
+		return stateNames[s]
+	}
+	return fmt.Sprintf("AppState(%d)", int(s))
+}
+
+func InitialModel(initialToken string, logo string, clientOpts ...api.Option) MainModel {
 	var state AppState
 
 	s := spinner.New()
@@ -40,6 +111,8 @@ func InitialModel(initialToken string, logo string) MainModel {
 
 	tokenInput := textinput.New()
 	tokenInput.Placeholder = "Enter your API token"
+	tokenInput.EchoMode = textinput.EchoPassword
+	tokenInput.EchoCharacter = '•'
 	tokenInput.CharLimit = 256
 	tokenInput.Width = 50
 	tokenInput.Cursor.Blink = true
@@ -59,15 +132,108 @@ func InitialModel(initialToken string, logo string) MainModel {
 	nricInput.Cursor.Blink = true
 	nricInput.Focus()
 
+	limitInput := textinput.New()
+	limitInput.Placeholder = "Spending limit, 0 for none"
+	limitInput.CharLimit = 16
+	limitInput.Width = 50
+	limitInput.Cursor.Blink = true
+	limitInput.Focus()
+
+	justificationInput := textinput.New()
+	justificationInput.Placeholder = "Reason for this lookup, e.g. case or ticket number"
+	justificationInput.CharLimit = 256
+	justificationInput.Width = 50
+	justificationInput.Cursor.Blink = true
+
+	reportInput := textinput.New()
+	reportInput.Prompt = "Comment: "
+	reportInput.Placeholder = "What is wrong, e.g. moved out in 2024"
+	reportInput.CharLimit = 512
+	reportInput.Width = 50
+	reportInput.Cursor.Blink = true
+
+	stepUpInput := textinput.New()
+	stepUpInput.Placeholder = "123456"
+	stepUpInput.CharLimit = 6
+	stepUpInput.Width = 10
+	stepUpInput.Validate = digitsOnly
+	stepUpInput.Cursor.Blink = true
+
+	helpInput := textinput.New()
+	helpInput.Placeholder = "Search help"
+	helpInput.CharLimit = 64
+	helpInput.Width = 50
+	helpInput.Cursor.Blink = true
+
+	helpList := newList(nil, 0, 0)
+	helpList.Title = "Help"
+	helpList.SetFilteringEnabled(false)
+	helpList.SetShowHelp(false)
+
+	menuList := newList(menuItems(nil, nil, false), 0, 0)
+
+	if initialToken == "" {
+		state = StateTokenInput
+		tokenInput.Focus()
+	} else {
+		state = StateMainMenu
+	}
+
+	return MainModel{
+		State:      state,
+		Spinner:    s,
+		Doc:        docStyle,
+		TokenInput: tokenInput,
+		NameInput:  nameInput,
+		NRICInput:  nricInput,
+		LimitInput: limitInput,
+
+		JustificationInput: justificationInput,
+		StepUpInput:        stepUpInput,
+		ReportInput:        reportInput,
+		APIClient:          api.NewClient(initialToken, clientOpts...),
+		ClientOpts:         clientOpts,
+		APIToken:           initialToken,
+		Logo:               logo,
+		List:               newList(nil, 0, 0),
+		Menu:               menuList,
+		Details:            viewport.New(0, 0),
+		HelpInput:          helpInput,
+		HelpList:           helpList,
+		HelpView:           viewport.New(0, 0),
+		AUPView:            viewport.New(0, 0),
+		Page:               1,
+		Session:            audit.NewSession(),
+		Recent:             audit.NewRecent(recentWindow),
+	}
+}
+
+// newList is list.New with the default delegate, and ? relabelled since
+// it opens context help rather than the list's full key help.
+func newList(items []list.Item, width, height int) list.Model {
+	l := list.New(items, list.NewDefaultDelegate(), width, height)
+	l.KeyMap.ShowFullHelp.SetHelp("?", "help")
+	l.KeyMap.CloseFullHelp.SetHelp("?", "help")
+	return l
+}
+
+// menuItems builds the main menu. Until the acceptable-use policy is
+// accepted the trace entries say they are locked, and lead to the policy.
+func menuItems(user *api.User, policy *api.Policy, aupAccepted bool) []list.Item {
+	traceName, traceNRIC := "Find people using their name", "Find people using their mykad"
+	if !aupAccepted {
+		traceName = "Locked until you accept the acceptable-use policy"
+		traceNRIC = traceName
+	}
 	items := []list.Item{
 		menuItem{
 			Name:  "Trace Name",
-			Desc:  "Find people using their name",
+			Desc:  traceName,
 			State: StateTraceNameInput,
 		},
 		menuItem{
 			Name:  "Trace Mykad",
-			Desc:  "Find people using their mykad",
+			Desc:  traceNRIC,
 			State: StateTraceNRICInput,
 		},
 		menuItem{
@@ -75,38 +241,73 @@ func InitialModel(initialToken string, logo string) MainModel {
 			Desc:  "Your search history",
 			State: StateHistory,
 		},
+		menuItem{
+			Name:  "My Reports",
+			Desc:  "Records you reported as inaccurate",
+			State: StateReports,
+		},
+		menuItem{
+			Name:  "Usage",
+			Desc:  "Spend totals and balance projection",
+			State: StateUsage,
+		},
+		menuItem{
+			Name:  "Tokens",
+			Desc:  "Active tokens, rotation and revocation",
+			State: StateTokens,
+		},
 		menuItem{
 			Name:  "Replace Token",
 			Desc:  "Replace your old token with a new one",
 			State: StateTokenInput,
 		},
 	}
-	menuList := list.New(items, list.NewDefaultDelegate(), 0, 0)
 
-	if initialToken == "" {
-		state = StateTokenInput
-		tokenInput.Focus()
-	} else {
-		state = StateMainMenu
+	if policy != nil && policy.ApprovalsRequired {
+		items = append(items, menuItem{
+			Name:  "Approvals",
+			Desc:  "Lookups waiting for sign-off",
+			State: StateApprovals,
+		})
 	}
-
-	return MainModel{
-		State:      state,
-		Spinner:    s,
-		Doc:        docStyle,
-		TokenInput: tokenInput,
-		NameInput:  nameInput,
-		NRICInput:  nricInput,
-		APIClient:  api.NewClient(initialToken),
-		APIToken:   initialToken,
-		Logo:       logo,
-		Menu:       menuList,
-		Page:       1,
+	if user != nil && user.CanManageTeam() {
+		items = append(items, menuItem{
+			Name:  "Team",
+			Desc:  "Manage members, tokens and spending limits",
+			State: StateTeam,
+		})
 	}
+	items = append(items, menuItem{
+		Name:  "Acceptable Use",
+		Desc:  "The policy you agree to before tracing",
+		State: StateAUP,
+	}, menuItem{
+		Name:  "Help",
+		Desc:  "Keys, costs and acceptable use",
+		State: StateHelp,
+	}, menuItem{
+		Name:  "About",
+		Desc:  "Version and build information",
+		State: StateAbout,
+	})
+	return items
+}
+
+func (m MainModel) newClient(token string) *api.Client {
+	return api.NewClient(token, m.ClientOpts...)
+}
+
+// signedBy returns the client options with requests signed by secret, or
+// not signed at all when it is nil, whatever secret they carried before.
+func (m MainModel) signedBy(secret []byte) []api.Option {
+	return append(slices.Clip(m.ClientOpts), api.WithSigningSecret(secret))
 }
 
 func (m MainModel) Init() tea.Cmd {
-	return m.Spinner.Tick
+	if m.APIToken == "" {
+		return m.Spinner.Tick
+	}
+	return tea.Batch(m.Spinner.Tick, m.FetchAccount(), m.FetchPolicy(), m.FetchRules(), m.FetchAUP())
 }
 
 func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -118,16 +319,28 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case tea.KeyCtrlC, tea.KeyCtrlD:
 			return m, tea.Quit
 		}
+		if m.wantsContextHelp(msg) {
+			return m.showTopic(contextTopics[m.State], m.State), nil
+		}
 
 		switch m.State {
 		case StateTokenInput:
 			switch msg.Type {
 			case tea.KeyEnter:
-				m.APIToken = m.TokenInput.Value()
-				m.APIClient = api.NewClient(m.APIToken)
-				m.State = StateMainMenu
-				m.NameInput.Focus()
-				utils.SaveToken(m.APIToken)
+				token := strings.TrimSpace(m.TokenInput.Value())
+				if token == "" {
+					m.LoginError = "Enter a token to continue"
+					return m, nil
+				}
+				m.State = StateLoading
+				return m, m.VerifyToken(token)
+			case tea.KeyEsc:
+				// Only when replacing a token; there is nowhere to go back to
+				// before the first login.
+				if m.APIToken != "" {
+					m.State = StateMainMenu
+					return m, nil
+				}
 			}
 
 			m.TokenInput, cmd = m.TokenInput.Update(msg)
@@ -135,9 +348,12 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.Type {
 			case tea.KeyEnter:
 				name := m.NameInput.Value()
-				m.State = StateLoading
 				m.Page = 1
-				return m, m.FetchName(name)
+				m.Reason = ""
+				return m.lookup(m.FetchName(name), audit.LookupName)
+			case tea.KeyEsc:
+				m.State = StateMainMenu
+				return m, nil
 			}
 
 			m.NameInput, cmd = m.NameInput.Update(msg)
@@ -145,39 +361,104 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.String() {
 			case "enter":
 				if item, ok := m.List.SelectedItem().(nameItem); ok {
-					m.State = StateLoading
-					return m, m.FetchID(item.item.ID)
+					if m.approvalsRequired() {
+						return m.requestApproval(api.SubmitApprovalRequest{Kind: api.ApprovalTraceDetail, RecordID: item.item.ID}), nil
+					}
+					return m.lookup(m.FetchID(item.item.ID), audit.LookupDetail)
+				}
+			case "v", "V":
+				if m.masked() {
+					return m.withStepUp(nil)
 				}
 			case "n", "N":
 				m.Page += 1
-				m.State = StateLoading
 				name := m.NameInput.Value()
-				return m, m.FetchName(name)
+				return m.lookup(m.FetchName(name), audit.LookupName)
 			case "p", "P":
 				m.Page -= 1
-				m.State = StateLoading
 				name := m.NameInput.Value()
-				return m, m.FetchName(name)
+				return m.lookup(m.FetchName(name), audit.LookupName)
 			case "m":
 				m.State = StateMainMenu
 			}
 			m.List, cmd = m.List.Update(msg)
 		case StateTraceDetails:
-			m.Relations = nil
-			switch msg.String() {
-			case "n", "N":
-				m.State = StateLoading
-				m.Offset += 1
-				return m, m.FetchRelations(m.UserID)
-			case "p", "P":
-				m.State = StateLoading
-				m.Offset -= 1
-				return m, m.FetchRelations(m.UserID)
+			keys := m.Details.KeyMap
+			switch {
+			case msg.String() == "n" || msg.String() == "N":
+				return m.pageRelations(1)
+			case msg.String() == "p" || msg.String() == "P":
+				return m.pageRelations(-1)
+			case msg.String() == "r" || msg.String() == "R":
+				return m.openReport(), nil
+			case key.Matches(msg, keys.Up, keys.Down, keys.PageUp, keys.PageDown, keys.HalfPageUp, keys.HalfPageDown):
+				m.Details, cmd = m.Details.Update(msg)
 			default:
+				m.Relations = nil
 				m.State = StateMainMenu
 			}
-		case StateError:
+		case StateError, StateDenied:
+			m.State = StateMainMenu
+			if m.User == nil && m.APIToken != "" {
+				return m, m.FetchAccount()
+			}
+		case StateConfirm:
+			return m.updateConfirm(msg)
+		case StateAnomaly:
+			if msg.Type == tea.KeyEnter {
+				m = m.acknowledge()
+			}
+		case StateUpdateRequired:
+			return m, tea.Quit
+		case StateAbout:
 			m.State = StateMainMenu
+		case StateHelp:
+			switch msg.Type {
+			case tea.KeyEsc:
+				m.State = StateMainMenu
+				return m, nil
+			case tea.KeyEnter:
+				if item, ok := m.HelpList.SelectedItem().(helpItem); ok {
+					return m.showTopic(item.topic.ID, StateHelp), nil
+				}
+				return m, nil
+			case tea.KeyUp, tea.KeyDown:
+				m.HelpList, cmd = m.HelpList.Update(msg)
+				return m, cmd
+			}
+			m.HelpInput, cmd = m.HelpInput.Update(msg)
+			m = m.searchHelp()
+		case StateAUP:
+			keys := m.AUPView.KeyMap
+			switch {
+			case key.Matches(msg, keys.Up, keys.Down, keys.PageUp, keys.PageDown, keys.HalfPageUp, keys.HalfPageDown):
+				m.AUPView, cmd = m.AUPView.Update(msg)
+			case msg.String() == "a" && !m.aupAccepted():
+				m.State = StateLoading
+				return m, m.AcceptAUP(m.AUP.Version)
+			case msg.Type == tea.KeyEsc:
+				m.State = StateMainMenu
+			}
+		case StateHelpTopic:
+			keys := m.HelpView.KeyMap
+			if key.Matches(msg, keys.Up, keys.Down, keys.PageUp, keys.PageDown, keys.HalfPageUp, keys.HalfPageDown) {
+				m.HelpView, cmd = m.HelpView.Update(msg)
+			} else {
+				m.State = m.HelpReturn
+			}
+		case StateOnboarding:
+			switch msg.String() {
+			case "enter", "n", "right", " ":
+				if m.OnboardingPage == len(help.Onboarding())-1 {
+					m = m.finishOnboarding()
+				} else {
+					m.OnboardingPage++
+				}
+			case "p", "left":
+				m.OnboardingPage = max(0, m.OnboardingPage-1)
+			case "esc", "s", "q":
+				m = m.finishOnboarding()
+			}
 		case StateMainMenu:
 			switch msg.Type {
 			case tea.KeyEnter:
@@ -186,6 +467,34 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					case StateHistory:
 						m.State = StateLoading
 						return m, m.FetchHistory()
+					case StateUsage:
+						m.State = StateLoading
+						return m, m.FetchUsage()
+					case StateTeam:
+						m.State = StateLoading
+						return m, m.FetchTeam()
+					case StateTokens:
+						m.State = StateLoading
+						return m, m.FetchTokens()
+					case StateApprovals:
+						m.State = StateLoading
+						return m, m.FetchApprovals()
+					case StateReports:
+						m.State = StateLoading
+						return m, m.FetchReports()
+					case StateTraceNameInput, StateTraceNRICInput:
+						if !m.aupAccepted() {
+							return m.lockedAUP()
+						}
+						m.State = item.State
+					case StateAUP:
+						return m.lockedAUP()
+					case StateHelp:
+						m = m.openHelpSearch()
+					case StateTokenInput:
+						m.TokenInput.Reset()
+						m.LoginError = ""
+						m.State = StateTokenInput
 					default:
 						m.State = item.State
 					}
@@ -196,8 +505,14 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			switch msg.Type {
 			case tea.KeyEnter:
 				nric := m.NRICInput.Value()
-				m.State = StateLoading
-				return m, m.FetchNRIC(nric)
+				if m.approvalsRequired() {
+					return m.requestApproval(api.SubmitApprovalRequest{Kind: api.ApprovalTraceNRIC, NRIC: nric}), nil
+				}
+				m.Reason = ""
+				return m.lookup(m.FetchNRIC(nric), audit.LookupNRIC)
+			case tea.KeyEsc:
+				m.State = StateMainMenu
+				return m, nil
 			}
 			m.NRICInput, cmd = m.NRICInput.Update(msg)
 		case StateHistory:
@@ -214,22 +529,204 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.State = StateMainMenu
 			}
 			m.List, cmd = m.List.Update(msg)
+		case StateUsage:
+			m.Usage = nil
+			m.State = StateMainMenu
+		case StateTeam:
+			if m.List.FilterState() == list.Filtering {
+				m.List, cmd = m.List.Update(msg)
+				break
+			}
+			item, ok := m.List.SelectedItem().(memberItem)
+			switch msg.String() {
+			case "t", "T":
+				if ok {
+					m.State = StateLoading
+					return m, m.IssueMemberToken(item.member)
+				}
+			case "r", "R":
+				if ok {
+					m.SelectedMember = &item.member
+					m.List = m.newTokenList(item.member)
+					m.State = StateTeamTokens
+					return m, nil
+				}
+			case "l", "L":
+				if ok {
+					m.SelectedMember = &item.member
+					m.LimitInput.SetValue("")
+					m.State = StateTeamLimitInput
+					return m, nil
+				}
+			case "s", "S":
+				if ok && item.member.Suspended {
+					m.State = StateLoading
+					return m, m.SuspendMember(item.member.ID, false)
+				}
+				if ok {
+					prompt := fmt.Sprintf("Suspend %s? Their tokens stop working until you unsuspend them.", item.member.Name)
+					return m.confirm(prompt, m.SuspendMember(item.member.ID, true)), nil
+				}
+			case "m", "M":
+				m.State = StateMainMenu
+				return m, nil
+			}
+			m.List, cmd = m.List.Update(msg)
+		case StateTeamTokens:
+			switch msg.String() {
+			case "enter":
+				if item, ok := m.List.SelectedItem().(tokenItem); ok {
+					prompt := fmt.Sprintf("Revoke token %s… of %s? It stops working at once.", item.info.Prefix, m.SelectedMember.Name)
+					return m.confirm(prompt, m.RevokeMemberToken(m.SelectedMember.ID, item.info.ID)), nil
+				}
+			case "esc", "m", "M":
+				m.State = StateLoading
+				return m, m.FetchTeam()
+			}
+			m.List, cmd = m.List.Update(msg)
+		case StateTeamLimitInput:
+			switch msg.Type {
+			case tea.KeyEnter:
+				limit, err := strconv.ParseFloat(strings.TrimSpace(m.LimitInput.Value()), 64)
+				if err != nil || limit < 0 {
+					m.State = StateError
+					m.ErrorMessage = "Spending limit must be an amount of 0 or more, 0 meaning no limit"
+					return m, nil
+				}
+				m.State = StateLoading
+				return m, m.SetMemberLimit(m.SelectedMember.ID, limit)
+			case tea.KeyEsc:
+				m.State = StateLoading
+				return m, m.FetchTeam()
+			}
+			m.LimitInput, cmd = m.LimitInput.Update(msg)
+		case StateTeamNewToken:
+			m.IssuedToken = ""
+			m.State = StateLoading
+			return m, m.FetchTeam()
+		case StateJustification:
+			switch msg.Type {
+			case tea.KeyEnter:
+				reason := strings.TrimSpace(m.JustificationInput.Value())
+				if reason == "" {
+					return m, nil
+				}
+				if m.PendingLookup == nil {
+					m.Reason = reason
+					next := m.JustifyNext
+					m.JustifyNext = nil
+					return m.lookup(next, m.JustifyKind)
+				}
+				req := *m.PendingLookup
+				req.Justification = reason
+				m.PendingLookup = nil
+				m.State = StateLoading
+				return m, m.SubmitApproval(req)
+			case tea.KeyEsc:
+				m.PendingLookup = nil
+				m.JustifyNext = nil
+				m.State = StateMainMenu
+				return m, nil
+			}
+			m.JustificationInput, cmd = m.JustificationInput.Update(msg)
+		case StateApprovals:
+			if m.List.FilterState() == list.Filtering {
+				m.List, cmd = m.List.Update(msg)
+				break
+			}
+			item, ok := m.List.SelectedItem().(approvalItem)
+			switch msg.String() {
+			case "enter":
+				if ok && item.mine && item.approval.Status == api.ApprovalApproved {
+					m.Reason = item.approval.Justification
+					kind := audit.LookupDetail
+					switch item.approval.Kind {
+					case api.ApprovalTraceNRIC:
+						kind = audit.LookupNRIC
+					case api.ApprovalTraceRelations:
+						kind = audit.LookupRelations
+						m.UserID = item.approval.RecordID
+					}
+					return m.lookup(m.FetchApproved(item.approval), kind)
+				}
+			case "a", "A", "x", "X":
+				if ok && !item.mine {
+					approve := strings.EqualFold(msg.String(), "a")
+					verb := "Reject"
+					if approve {
+						verb = "Approve"
+					}
+					prompt := fmt.Sprintf("%s %s's request: %s?", verb, item.approval.RequestedBy, item.target())
+					return m.confirm(prompt, m.DecideApproval(item.approval.ID, approve)), nil
+				}
+			case "r", "R":
+				m.State = StateLoading
+				return m, m.FetchApprovals()
+			case "m", "M":
+				m.State = StateMainMenu
+				return m, nil
+			}
+			m.List, cmd = m.List.Update(msg)
+		case StateReport:
+			return m.updateReport(msg)
+		case StateReports:
+			if m.List.FilterState() == list.Filtering {
+				m.List, cmd = m.List.Update(msg)
+				break
+			}
+			switch msg.String() {
+			case "r", "R":
+				m.State = StateLoading
+				return m, m.FetchReports()
+			case "m", "M":
+				m.State = StateMainMenu
+				return m, nil
+			}
+			m.List, cmd = m.List.Update(msg)
+		case StateStepUp:
+			switch msg.Type {
+			case tea.KeyEnter:
+				code := m.StepUpInput.Value()
+				if len(code) != m.StepUpInput.CharLimit {
+					m.StepUpError = "Enter the 6 digit code from your authenticator app"
+					return m, nil
+				}
+				m.State = StateLoading
+				return m, m.VerifyStepUp(code)
+			case tea.KeyEsc:
+				m.StepUpNext = nil
+				m.State = m.StepUpReturn
+				return m, nil
+			}
+			m.StepUpInput, cmd = m.StepUpInput.Update(msg)
+		case StateTokens:
+			switch msg.String() {
+			case "r", "R":
+				m.State = StateLoading
+				return m, m.RotateToken()
+			case "d", "D":
+				if item, ok := m.List.SelectedItem().(tokenItem); ok {
+					prompt := fmt.Sprintf("Revoke token %s…? It stops working at once.", item.info.Prefix)
+					if item.info.Current {
+						prompt = "Revoke the token on this device? You will be signed out."
+					}
+					return m.confirm(prompt, m.RevokeToken(item.info)), nil
+				}
+			case "x", "X":
+				return m.confirm("Revoke the token on this device? You will be signed out.", m.RevokeToken(api.TokenInfo{Current: true})), nil
+			case "m", "M":
+				m.State = StateMainMenu
+				return m, nil
+			}
+			m.List, cmd = m.List.Update(msg)
 		}
+	case tea.MouseMsg:
+		return m.handleMouse(msg)
 	case tea.WindowSizeMsg:
-		h, v := m.Doc.GetFrameSize()
-		m.Width = msg.Width - h
-		m.Height = msg.Height - v
-		switch m.State {
-		case StateTraceNameResults:
-			m.List.SetSize(m.Width, m.Height)
-		case StateMainMenu:
-			m.Menu.SetSize(m.Width, m.Height)
-		}
+		m = m.resize(msg.Width, msg.Height)
 	case api.TraceNameMsg:
 		if msg.Err != nil {
-			m.State = StateError
-			m.ErrorMessage = fmt.Sprintf("Error fetching names: %s", msg.Err.Error())
-			return m, nil
+			return m.fail("Error fetching names", msg.Err), nil
 		}
 
 		if len(msg.Items) == 0 {
@@ -237,28 +734,32 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			m.ErrorMessage = "No results found for that name"
 		} else {
 			var items []list.Item
+			withheld := m.withholds("mykad")
 			for _, item := range msg.Items {
+				if withheld {
+					item.Mykad = ""
+				}
 				items = append(items, nameItem{
-					item: item,
+					item:     item,
+					masked:   m.masked(),
+					withheld: withheld,
 				})
 			}
 
-			listKeys := newListKeyMap()
-			m.List = list.New(items, list.NewDefaultDelegate(), m.Width, m.Height)
+			m.List = newList(items, m.Width, m.listHeight())
 			m.List.Title = "Trace Results"
-			m.List.AdditionalShortHelpKeys = func() []key.Binding {
-				return []key.Binding{
-					listKeys.toggleNextPage,
-					listKeys.togglePreviousPage,
-				}
-			}
+			m.List.AdditionalShortHelpKeys = nameListKeys(m.masked() && !withheld)
 			m.State = StateTraceNameResults
+			m, cmd = m.watchAnomalies()
 		}
 	case api.TraceDetailsMsg:
 		if msg.Err != nil {
-			m.State = StateError
-			m.ErrorMessage = fmt.Sprintf("Error fetching details: %s", msg.Err.Error())
-			return m, nil
+			if api.IsStepUpRequired(msg.Err) {
+				// The backend's grace window ended before ours; the next
+				// lookup asks for a code.
+				m.StepUpUntil = time.Time{}
+			}
+			return m.fail("Error fetching details", msg.Err), nil
 		}
 
 		if len(msg.Details) == 0 {
@@ -267,14 +768,18 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.UserDetails = &msg.Details[0]
 			m.UserID = m.UserDetails.ID
+			m.Withheld = msg.Withheld
+			m.ReportNotice = ""
+			m.audit(audit.Event{Action: audit.ActionRecordView, RecordID: m.UserDetails.ID})
 			m.Offset = 0
 			m.State = StateTraceDetails
+			m = m.refreshDetails()
+			m.Details.GotoTop()
+			m, cmd = m.watchAnomalies()
 		}
 	case api.TraceRelationsMsg:
 		if msg.Err != nil {
-			m.State = StateError
-			m.ErrorMessage = fmt.Sprintf("Can't find relationships: %s", msg.Err.Error())
-			return m, nil
+			return m.fail("Can't find relationships", msg.Err), nil
 		}
 
 		if len(msg.Details) == 0 {
@@ -283,11 +788,22 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		} else {
 			m.UserDetails = &msg.Details[0]
 			m.Relations = &msg.Relations
+			m.Offset = msg.Offset
+			m.Withheld = msg.Withheld
+			m.ReportNotice = ""
+			m.audit(audit.Event{Action: audit.ActionRecordView, RecordID: m.UserDetails.ID})
 			m.State = StateTraceDetails
+			m = m.refreshDetails()
+			m.Details.GotoTop()
+			m, cmd = m.watchAnomalies()
 		}
 	case spinner.TickMsg:
 		m.Spinner, cmd = m.Spinner.Update(msg)
 	case api.HistoryMsg:
+		if msg.Err != nil {
+			return m.fail("Error fetching history", msg.Err), nil
+		}
+
 		m.State = StateHistory
 		var items []list.Item
 
@@ -298,7 +814,7 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 
 		listKeys := newListKeyMap()
-		m.List = list.New(items, list.NewDefaultDelegate(), m.Width, m.Height)
+		m.List = newList(items, m.Width, m.listHeight())
 		m.List.Title = "Search History"
 		m.List.FullHelp()
 		m.List.AdditionalShortHelpKeys = func() []key.Binding {
@@ -307,10 +823,299 @@ func (m MainModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				listKeys.togglePreviousPage,
 			}
 		}
+	case api.UsageMsg:
+		if msg.Err != nil {
+			return m.fail("Error building usage report", msg.Err), nil
+		}
+
+		report := usage.Build(msg.Items, msg.User, time.Now())
+		report.Partial = msg.Partial
+		m.Usage = &report
+		m.State = StateUsage
+	case UpdateAvailableMsg:
+		m.Menu.Title = fmt.Sprintf("Update %s available, run synthera update", msg.Version)
+	case api.AccountMsg:
+		if msg.Err != nil {
+			switch {
+			case m.APIToken == "":
+				// No token yet, so the token prompt is already up.
+			case api.IsUnauthorized(msg.Err):
+				m.State = StateTokenInput
+				m.LoginError = "Your saved token was rejected, enter a new one"
+			default:
+				m = m.fail("Error loading account", msg.Err)
+				if m.State == StateError {
+					m.ErrorMessage += ". Tracing stays locked until it loads; press any key to try again."
+				}
+			}
+			return m, nil
+		}
+		m.User = &msg.User
+		m.audit(audit.Event{Action: audit.ActionSessionStart})
+		m = m.gateAUP()
+		cmd = tea.Batch(m.Menu.SetItems(menuItems(m.User, m.Policy, m.aupAccepted())), m.FetchFieldPolicy())
+	case api.FieldPolicyMsg:
+		switch {
+		case msg.Err == nil:
+			m.FieldPolicy = &msg.Policy
+		case api.IsNotFound(msg.Err):
+			// The backend predates field policies, so nothing is hidden.
+			m.FieldPolicy = &api.FieldPolicy{}
+		}
+	case api.AUPMsg:
+		switch {
+		case msg.Err == nil:
+			m.AUP, m.AUPErr, m.AUPLocal = &msg.AUP, nil, false
+		case api.IsNotFound(msg.Err):
+			// The backend doesn't publish a policy, so the built-in one
+			// applies and acceptance is only kept here.
+			m.AUP, m.AUPErr, m.AUPLocal = &api.AUP{Version: help.AUPVersion, Text: help.AUP()}, nil, true
+		default:
+			m.AUPErr = msg.Err
+		}
+		m = m.gateAUP()
+		cmd = m.Menu.SetItems(menuItems(m.User, m.Policy, m.aupAccepted()))
+	case api.AUPAcceptMsg:
+		switch {
+		case msg.Err == nil:
+		case api.IsNotFound(msg.Err) && m.AUPLocal && m.User != nil:
+			// The backend doesn't track acceptance, so it is only kept here.
+			msg.Acceptance = api.AUPAcceptance{UserID: m.User.ID, Version: m.AUP.Version, AcceptedAt: time.Now().UTC()}
+		default:
+			// Most likely the policy changed while it was on screen.
+			return m.fail("Error recording your acceptance", msg.Err), m.FetchAUP()
+		}
+		m = m.recordAUP(msg.Acceptance)
+		cmd = tea.Batch(
+			m.Menu.SetItems(menuItems(m.User, m.Policy, m.aupAccepted())),
+			m.Menu.NewStatusMessage(fmt.Sprintf("Accepted the acceptable-use policy, version %s", msg.Acceptance.Version)),
+		)
+	case api.RulesMsg:
+		switch {
+		case msg.Err == nil:
+			m.Rules, m.RulesErr = &msg.Rules, nil
+		case api.IsNotFound(msg.Err) && (m.LocalRules != nil || m.LocalRulesErr != nil):
+			// The backend has no rules, so the signed local file applies.
+			m.Rules, m.RulesErr = m.LocalRules, m.LocalRulesErr
+		case api.IsNotFound(msg.Err):
+			// The backend has no rules, so lookups are unrestricted.
+			m.Rules, m.RulesErr = &api.Rules{}, nil
+		default:
+			m.Rules, m.RulesErr = nil, msg.Err
+		}
+	case api.PolicyMsg:
+		switch {
+		case msg.Err == nil:
+			m.Policy = &msg.Policy
+		case api.IsNotFound(msg.Err):
+			// The backend predates policies, so nothing is enforced.
+			m.Policy = &api.Policy{}
+		}
+		cmd = m.Menu.SetItems(menuItems(m.User, m.Policy, m.aupAccepted()))
+	case api.LoginMsg:
+		m.TokenInput.Reset()
+		if msg.Err != nil {
+			if api.IsIncompatible(msg.Err) {
+				return m.fail("Error verifying token", msg.Err), nil
+			}
+			m.State = StateTokenInput
+			if api.IsUnauthorized(msg.Err) {
+				m.LoginError = "That token was rejected, check it and try again"
+			} else {
+				m.LoginError = fmt.Sprintf("Could not verify token: %s", msg.Err.Error())
+			}
+			return m, nil
+		}
+
+		m.LoginError = ""
+		m.APIToken = msg.Token
+		// A token entered here is never signed; the old token's secret
+		// would only get every request rejected.
+		m.ClientOpts = m.signedBy(nil)
+		m.APIClient = m.newClient(m.APIToken)
+		m.User = &msg.User
+		// Acceptance is per user, so the policy is fetched again for them.
+		m.AUP, m.AUPErr, m.AUPLocal = nil, nil, false
+		m.audit(audit.Event{Action: audit.ActionSessionStart})
+		m.State = StateMainMenu
+		m.NameInput.Focus()
+		if err := utils.SaveCredentials(m.APIToken, ""); err != nil {
+			m.State = StateError
+			m.ErrorMessage = fmt.Sprintf("Token could not be saved, you will need to enter it again next time: %s", err.Error())
+		} else if !m.Onboarded {
+			m.OnboardingPage = 0
+			m.State = StateOnboarding
+		}
+		return m, tea.Batch(
+			m.Menu.SetItems(menuItems(m.User, m.Policy, m.aupAccepted())),
+			m.Menu.NewStatusMessage(fmt.Sprintf("Logged in as %s (%s)", msg.User.Name, msg.User.Role)),
+			m.FetchPolicy(),
+			m.FetchFieldPolicy(),
+			m.FetchRules(),
+			m.FetchAUP(),
+		)
+	case api.TeamMsg:
+		if msg.Err != nil {
+			return m.fail("Error fetching team", msg.Err), nil
+		}
+
+		var items []list.Item
+		for _, member := range msg.Members {
+			items = append(items, memberItem{
+				member: member,
+			})
+		}
+
+		teamKeys := newTeamKeyMap()
+		m.List = newList(items, m.Width, m.listHeight())
+		m.List.Title = "Team"
+		m.List.AdditionalShortHelpKeys = func() []key.Binding {
+			return []key.Binding{
+				teamKeys.issueToken,
+				teamKeys.revokeToken,
+				teamKeys.setLimit,
+				teamKeys.toggleSuspend,
+			}
+		}
+		m.State = StateTeam
+	case api.TeamMemberMsg:
+		if msg.Err != nil {
+			return m.fail("Error updating member", msg.Err), nil
+		}
+		return m, m.FetchTeam()
+	case api.TeamTokenMsg:
+		if msg.Err != nil {
+			return m.fail("Error issuing token", msg.Err), nil
+		}
+		m.SelectedMember = &msg.Member
+		m.IssuedToken = msg.Token
+		m.State = StateTeamNewToken
+	case api.TokensMsg:
+		if msg.Err != nil {
+			return m.fail("Error fetching tokens", msg.Err), nil
+		}
+
+		var items []list.Item
+		for _, info := range msg.Tokens {
+			items = append(items, tokenItem{
+				info: info,
+			})
+		}
+
+		tokenKeys := newTokenKeyMap()
+		m.List = newList(items, m.Width, m.listHeight())
+		m.List.Title = "Active Tokens"
+		m.List.AdditionalShortHelpKeys = func() []key.Binding {
+			return []key.Binding{
+				tokenKeys.rotate,
+				tokenKeys.revoke,
+				tokenKeys.revokeDevice,
+			}
+		}
+		m.State = StateTokens
+	case api.StepUpMsg:
+		if msg.Err != nil {
+			if api.IsInvalidCode(msg.Err) {
+				m.StepUpInput.Reset()
+				m.StepUpError = "That code was not accepted, wait for the next one"
+				m.State = StateStepUp
+				return m, nil
+			}
+			return m.fail("Error verifying code", msg.Err), nil
+		}
+
+		m.StepUpUntil = time.Now().Add(msg.Grace)
+		m.audit(audit.Event{Action: audit.ActionStepUp})
+		m = m.unmask()
+		next := m.StepUpNext
+		m.StepUpNext = nil
+		if next == nil {
+			m.State = m.StepUpReturn
+			return m, nil
+		}
+		return m, next
+	case api.ApprovalsMsg:
+		if msg.Err != nil {
+			return m.fail("Error fetching approvals", msg.Err), nil
+		}
+		m.List = m.newApprovalList(msg.Mine, msg.Pending)
+		m.State = StateApprovals
+	case api.ApprovalMsg:
+		if msg.Err != nil {
+			return m.fail("Error updating approval", msg.Err), nil
+		}
+		m.audit(audit.Event{Action: audit.ActionApproval, RecordID: msg.Approval.RecordID, Detail: fmt.Sprintf("approval %d %s", msg.Approval.ID, msg.Approval.Status)})
+		return m, m.FetchApprovals()
+	case api.ReportsMsg:
+		if msg.Err != nil {
+			return m.fail("Error fetching reports", msg.Err), nil
+		}
+		m.List = m.newReportList(msg.Reports)
+		m.State = StateReports
+	case api.ReportMsg:
+		if msg.Err != nil {
+			return m.fail("Error submitting report", msg.Err), nil
+		}
+		m = m.reported(msg.Report)
+	case api.RotateTokenMsg:
+		if msg.Err != nil {
+			return m.fail("Error rotating token", msg.Err), nil
+		}
+
+		m.APIToken = msg.Token
+		if msg.SigningSecret != "" {
+			secret, err := hex.DecodeString(msg.SigningSecret)
+			if err != nil {
+				return m.fail("Token rotated but its signing secret is invalid", err), nil
+			}
+			m.ClientOpts = m.signedBy(secret)
+		}
+		m.APIClient = m.newClient(m.APIToken)
+		if err := utils.SaveCredentials(m.APIToken, msg.SigningSecret); err != nil {
+			copyNow := m.APIToken
+			if msg.SigningSecret != "" {
+				copyNow += ", signing secret " + msg.SigningSecret
+			}
+			m.State = StateError
+			m.ErrorMessage = fmt.Sprintf("Token rotated but could not be saved, copy it now: %s (%s)", copyNow, err.Error())
+			return m, nil
+		}
+		return m, m.FetchTokens()
+	case api.RevokeTokenMsg:
+		if msg.Err != nil {
+			return m.fail("Error revoking token", msg.Err), nil
+		}
+
+		if !msg.Current {
+			return m, m.FetchTokens()
+		}
+		m.APIToken = ""
+		m.APIClient = m.newClient("")
+		m.User = nil
+		cmd = m.Menu.SetItems(menuItems(nil, m.Policy, false))
+		m.TokenInput.Reset()
+		if err := utils.ClearCredentials(); err != nil {
+			m.State = StateError
+			m.ErrorMessage = fmt.Sprintf("Token revoked but the local copy could not be cleared: %s", err.Error())
+			return m, cmd
+		}
+		m.State = StateTokenInput
 	}
 	return m, cmd
 }
 
+// fail routes err to the error screen, or to the update screen when the
+// backend no longer speaks this client's API version.
+func (m MainModel) fail(context string, err error) MainModel {
+	m.ErrorMessage = fmt.Sprintf("%s: %s", context, err.Error())
+	if api.IsIncompatible(err) {
+		m.State = StateUpdateRequired
+	} else {
+		m.State = StateError
+	}
+	return m
+}
+
 func (m MainModel) FetchName(name string) tea.Cmd {
 	return func() tea.Msg {
 		items, err := m.APIClient.TraceName(name, m.Page)
@@ -323,20 +1128,65 @@ func (m MainModel) FetchName(name string) tea.Cmd {
 
 func (m MainModel) FetchID(id int) tea.Cmd {
 	return func() tea.Msg {
-		details, err := m.APIClient.TraceDetail(id)
+		client, err := m.records()
+		if err != nil {
+			return api.TraceDetailsMsg{Err: err}
+		}
+		details, err := client.TraceDetail(id)
 		return api.TraceDetailsMsg{
-			Details: details,
-			Err:     err,
+			Details:  details,
+			Withheld: client.Withheld(),
+			Err:      err,
+		}
+	}
+}
+
+// FetchApproved runs the lookup an approved request covers.
+func (m MainModel) FetchApproved(a api.Approval) tea.Cmd {
+	return func() tea.Msg {
+		client, err := m.records()
+		if err != nil {
+			return api.TraceDetailsMsg{Err: err}
+		}
+		if a.Kind == api.ApprovalTraceRelations {
+			details, relations, err := client.TraceRelationsApproved(a.RecordID, a.Offset, a.ID)
+			return api.TraceRelationsMsg{
+				Details:   details,
+				Relations: relations,
+				Offset:    a.Offset,
+				Withheld:  client.Withheld(),
+				Err:       err,
+			}
+		}
+		var details []api.TraceDetailID
+		if a.Kind == api.ApprovalTraceNRIC {
+			details, err = client.TraceNRICApproved(a.NRIC, a.ID)
+		} else {
+			details, err = client.TraceDetailApproved(a.RecordID, a.ID)
+		}
+		return api.TraceDetailsMsg{
+			Details:  details,
+			Withheld: client.Withheld(),
+			Err:      err,
 		}
 	}
 }
 
-func (m MainModel) FetchRelations(id int) tea.Cmd {
+// FetchRelations fetches the relationships of record id at offset. The
+// model's Offset only moves once the page arrives, so a refused lookup
+// leaves the user on the page they were reading.
+func (m MainModel) FetchRelations(id, offset int) tea.Cmd {
 	return func() tea.Msg {
-		details, relations, err := m.APIClient.TraceRelations(id, m.Offset)
+		client, err := m.records()
+		if err != nil {
+			return api.TraceRelationsMsg{Err: err}
+		}
+		details, relations, err := client.TraceRelations(id, offset)
 		return api.TraceRelationsMsg{
 			Details:   details,
 			Relations: relations,
+			Offset:    offset,
+			Withheld:  client.Withheld(),
 			Err:       err,
 		}
 	}
@@ -344,10 +1194,15 @@ func (m MainModel) FetchRelations(id int) tea.Cmd {
 
 func (m MainModel) FetchNRIC(nric string) tea.Cmd {
 	return func() tea.Msg {
-		details, err := m.APIClient.TraceNRIC(nric)
+		client, err := m.records()
+		if err != nil {
+			return api.TraceDetailsMsg{Err: err}
+		}
+		details, err := client.TraceNRIC(nric)
 		return api.TraceDetailsMsg{
-			Details: details,
-			Err:     err,
+			Details:  details,
+			Withheld: client.Withheld(),
+			Err:      err,
 		}
 	}
 }
@@ -361,3 +1216,287 @@ func (m MainModel) FetchHistory() tea.Cmd {
 		}
 	}
 }
+
+func (m MainModel) FetchUsage() tea.Cmd {
+	return func() tea.Msg {
+		user, err := m.APIClient.Account()
+		if err != nil {
+			return api.UsageMsg{Err: err}
+		}
+		items, err := m.APIClient.AllHistory()
+		truncated := errors.Is(err, api.ErrHistoryTruncated)
+		if truncated {
+			err = nil
+		}
+		return api.UsageMsg{
+			Items:   items,
+			User:    user,
+			Partial: truncated,
+			Err:     err,
+		}
+	}
+}
+
+func (m MainModel) FetchAccount() tea.Cmd {
+	return func() tea.Msg {
+		user, err := m.APIClient.Account()
+		return api.AccountMsg{
+			User: user,
+			Err:  err,
+		}
+	}
+}
+
+func (m MainModel) FetchFieldPolicy() tea.Cmd {
+	return func() tea.Msg {
+		if m.User == nil {
+			return nil
+		}
+		policy, err := m.APIClient.FieldPolicy(m.User.Role)
+		return api.FieldPolicyMsg{
+			Policy: policy,
+			Err:    err,
+		}
+	}
+}
+
+// ReportAnomaly tells the org admins about a finding. It is best effort;
+// the local audit log has the finding either way.
+func (m MainModel) ReportAnomaly(f anomaly.Finding, at time.Time) tea.Cmd {
+	return func() tea.Msg {
+		m.APIClient.ReportAnomaly(api.AnomalyReport{
+			Kind:       f.Kind,
+			Count:      f.Count,
+			RecordID:   f.RecordID,
+			Message:    f.Message,
+			Session:    m.Session,
+			DetectedAt: at.UTC(),
+		})
+		return nil
+	}
+}
+
+func (m MainModel) FetchAUP() tea.Cmd {
+	return func() tea.Msg {
+		aup, err := m.APIClient.AUP()
+		return api.AUPMsg{
+			AUP: aup,
+			Err: err,
+		}
+	}
+}
+
+func (m MainModel) AcceptAUP(version string) tea.Cmd {
+	return func() tea.Msg {
+		acceptance, err := m.APIClient.AcceptAUP(version)
+		return api.AUPAcceptMsg{
+			Acceptance: acceptance,
+			Err:        err,
+		}
+	}
+}
+
+func (m MainModel) FetchRules() tea.Cmd {
+	return func() tea.Msg {
+		rules, err := m.APIClient.Rules()
+		return api.RulesMsg{
+			Rules: rules,
+			Err:   err,
+		}
+	}
+}
+
+func (m MainModel) FetchPolicy() tea.Cmd {
+	return func() tea.Msg {
+		policy, err := m.APIClient.Policy()
+		return api.PolicyMsg{
+			Policy: policy,
+			Err:    err,
+		}
+	}
+}
+
+// VerifyToken checks token against the backend before anything is saved,
+// so a typo never replaces a working token.
+func (m MainModel) VerifyToken(token string) tea.Cmd {
+	return func() tea.Msg {
+		user, err := api.NewClient(token, m.signedBy(nil)...).Account()
+		return api.LoginMsg{
+			Token: token,
+			User:  user,
+			Err:   err,
+		}
+	}
+}
+
+func (m MainModel) FetchTeam() tea.Cmd {
+	return func() tea.Msg {
+		members, err := m.APIClient.TeamMembers()
+		return api.TeamMsg{
+			Members: members,
+			Err:     err,
+		}
+	}
+}
+
+func (m MainModel) IssueMemberToken(member api.TeamMember) tea.Cmd {
+	return func() tea.Msg {
+		token, _, err := m.APIClient.IssueMemberToken(member.ID)
+		return api.TeamTokenMsg{
+			Member: member,
+			Token:  token,
+			Err:    err,
+		}
+	}
+}
+
+func (m MainModel) RevokeMemberToken(memberID, tokenID int) tea.Cmd {
+	return func() tea.Msg {
+		member, err := m.APIClient.RevokeMemberToken(memberID, tokenID)
+		return api.TeamMemberMsg{
+			Member: member,
+			Err:    err,
+		}
+	}
+}
+
+func (m MainModel) SetMemberLimit(memberID int, limit float64) tea.Cmd {
+	return func() tea.Msg {
+		member, err := m.APIClient.SetMemberLimit(memberID, limit)
+		return api.TeamMemberMsg{
+			Member: member,
+			Err:    err,
+		}
+	}
+}
+
+func (m MainModel) SuspendMember(memberID int, suspended bool) tea.Cmd {
+	return func() tea.Msg {
+		member, err := m.APIClient.SuspendMember(memberID, suspended)
+		return api.TeamMemberMsg{
+			Member: member,
+			Err:    err,
+		}
+	}
+}
+
+func (m MainModel) newTokenList(member api.TeamMember) list.Model {
+	var items []list.Item
+	for _, info := range member.Tokens {
+		items = append(items, tokenItem{
+			info: info,
+		})
+	}
+
+	l := newList(items, m.Width, m.listHeight())
+	l.Title = fmt.Sprintf("Tokens for %s (enter to revoke)", member.Name)
+	return l
+}
+
+func (m MainModel) VerifyStepUp(code string) tea.Cmd {
+	return func() tea.Msg {
+		grace, err := m.APIClient.VerifyStepUp(code)
+		return api.StepUpMsg{
+			Grace: grace,
+			Err:   err,
+		}
+	}
+}
+
+func (m MainModel) SubmitApproval(req api.SubmitApprovalRequest) tea.Cmd {
+	return func() tea.Msg {
+		approval, err := m.APIClient.SubmitApproval(req)
+		return api.ApprovalMsg{
+			Approval: approval,
+			Err:      err,
+		}
+	}
+}
+
+// FetchApprovals loads the user's own requests and, for supervisors, the
+// ones waiting on them.
+func (m MainModel) FetchApprovals() tea.Cmd {
+	return func() tea.Msg {
+		mine, err := m.APIClient.MyApprovals()
+		if err != nil || m.User == nil || !m.User.CanApprove() {
+			return api.ApprovalsMsg{
+				Mine: mine,
+				Err:  err,
+			}
+		}
+		pending, err := m.APIClient.PendingApprovals()
+		return api.ApprovalsMsg{
+			Mine:    mine,
+			Pending: pending,
+			Err:     err,
+		}
+	}
+}
+
+func (m MainModel) SubmitReport(req api.SubmitReportRequest) tea.Cmd {
+	return func() tea.Msg {
+		report, err := m.APIClient.SubmitReport(req)
+		return api.ReportMsg{
+			Report: report,
+			Err:    err,
+		}
+	}
+}
+
+func (m MainModel) FetchReports() tea.Cmd {
+	return func() tea.Msg {
+		reports, err := m.APIClient.MyReports()
+		return api.ReportsMsg{
+			Reports: reports,
+			Err:     err,
+		}
+	}
+}
+
+func (m MainModel) DecideApproval(id int, approve bool) tea.Cmd {
+	return func() tea.Msg {
+		approval, err := m.APIClient.DecideApproval(id, approve)
+		return api.ApprovalMsg{
+			Approval: approval,
+			Err:      err,
+		}
+	}
+}
+
+func (m MainModel) FetchTokens() tea.Cmd {
+	return func() tea.Msg {
+		tokens, err := m.APIClient.Tokens()
+		return api.TokensMsg{
+			Tokens: tokens,
+			Err:    err,
+		}
+	}
+}
+
+func (m MainModel) RotateToken() tea.Cmd {
+	return func() tea.Msg {
+		token, secret, err := m.APIClient.RotateToken()
+		return api.RotateTokenMsg{
+			Token:         token,
+			SigningSecret: secret,
+			Err:           err,
+		}
+	}
+}
+
+// RevokeToken revokes info on the server. Revoking the token this session
+// is using signs the device out, which is how a lost laptop is cut off.
+func (m MainModel) RevokeToken(info api.TokenInfo) tea.Cmd {
+	return func() tea.Msg {
+		var err error
+		if info.Current {
+			err = m.APIClient.RevokeCurrentToken()
+		} else {
+			err = m.APIClient.RevokeToken(info.ID)
+		}
+		return api.RevokeTokenMsg{
+			Current: info.Current,
+			Err:     err,
+		}
+	}
+}
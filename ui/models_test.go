@@ -0,0 +1,264 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"synthera/api"
+	"synthera/api/mock"
+	"synthera/utils"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// relationsRequests records the body of every relationships lookup sent
+// through it.
+type relationsRequests []api.TraceRelationsRequest
+
+func (r *relationsRequests) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/trace/relations" {
+		body, err := io.ReadAll(req.Body)
+		if err != nil {
+			return nil, err
+		}
+		var sent api.TraceRelationsRequest
+		if err := json.Unmarshal(body, &sent); err != nil {
+			return nil, err
+		}
+		*r = append(*r, sent)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// isolate keeps the audit log and other state a test writes out of the
+// user's own directories.
+func isolate(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("HOME", dir)
+	t.Setenv("XDG_STATE_HOME", dir)
+	t.Setenv("XDG_CONFIG_HOME", dir)
+}
+
+// newTestModel is a model signed in to s with token, past the policy and
+// rules that gate lookups.
+func newTestModel(t *testing.T, s *mock.Server, token string, opts ...api.Option) MainModel {
+	t.Helper()
+	isolate(t)
+	m := InitialModel(token, "", append([]api.Option{api.WithBaseURL(s.URL)}, opts...)...)
+	user, err := m.APIClient.Account()
+	if err != nil {
+		t.Fatalf("Account: %v", err)
+	}
+	m.User = &user
+	m.AUP = &api.AUP{}
+	m.Rules = &api.Rules{}
+	m.FieldPolicy = &api.FieldPolicy{}
+	return m
+}
+
+// press sends key k to m and feeds the message its command returns back in.
+func press(t *testing.T, m MainModel, k string) MainModel {
+	t.Helper()
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)})
+	m = next.(MainModel)
+	if cmd == nil {
+		t.Fatalf("%q in %s started no lookup", k, m.State)
+	}
+	next, _ = m.Update(cmd())
+	return next.(MainModel)
+}
+
+func TestPageRelations(t *testing.T) {
+	s := mock.NewServer()
+	t.Cleanup(s.Close)
+	token := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+	s.AddRecord(api.TraceDetailID{ID: 7, Name: "ALI"})
+	s.AddRecord(api.TraceDetailID{ID: 8, Name: "SITI"})
+	s.AddRecord(api.TraceDetailID{ID: 9, Name: "AHMAD"})
+	s.AddRecord(api.TraceDetailID{ID: 10, Name: "NUR"})
+	s.AddRelation(7, 8, "Spouse")
+	s.AddRelation(7, 9, "Child")
+	s.AddRelation(7, 10, "Child")
+	s.AddRelation(8, 7, "Spouse")
+
+	var sent relationsRequests
+	m := newTestModel(t, s, token, api.WithHTTPTransport(&sent))
+	m.UserID = 7
+	m.State = StateTraceDetails
+
+	m = press(t, m, "n")
+	m = press(t, m, "n")
+
+	if len(sent) != 2 {
+		t.Fatalf("sent %d relationship lookups, want 2", len(sent))
+	}
+	for i, req := range sent {
+		if req.ID != 7 || req.Offset != i+1 {
+			t.Errorf("lookup %d = record %d at %d, want record 7 at %d", i+1, req.ID, req.Offset, i+1)
+		}
+	}
+	if m.State != StateTraceDetails || m.UserDetails == nil || m.UserDetails.ID != 10 {
+		t.Errorf("after paging twice state = %s, details = %+v, want record 10", m.State, m.UserDetails)
+	}
+	if m.UserID != 7 || m.Offset != 2 {
+		t.Errorf("tracing record %d at %d, want record 7 at 2", m.UserID, m.Offset)
+	}
+}
+
+// signatures records whether each request sent through it was signed.
+type signatures []bool
+
+func (s *signatures) RoundTrip(req *http.Request) (*http.Response, error) {
+	*s = append(*s, req.Header.Get(api.HeaderSignature) != "")
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLoginDropsSigningSecret(t *testing.T) {
+	s := mock.NewServer()
+	t.Cleanup(s.Close)
+	old := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+	secret := s.RequireSigning(old)
+	fresh := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+
+	var signed signatures
+	m := newTestModel(t, s, old, api.WithSigningSecret(secret), api.WithHTTPTransport(&signed))
+	config := filepath.Join(t.TempDir(), ".token.json")
+	if err := os.WriteFile(config, []byte(`{"version": 1, "api_token": "`+old+`", "signing_secret": "`+hex.EncodeToString(secret)+`"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	utils.SetConfigPath(config)
+	t.Cleanup(func() { utils.SetConfigPath("") })
+
+	next, _ := m.Update(m.VerifyToken(fresh)())
+	m = next.(MainModel)
+	if m.State == StateTokenInput {
+		t.Fatalf("login refused: %s", m.LoginError)
+	}
+	if _, err := m.APIClient.Account(); err != nil {
+		t.Fatalf("Account after login: %v", err)
+	}
+	// The first request is newTestModel's, with the old token.
+	for i, sig := range signed[1:] {
+		if sig {
+			t.Errorf("request %d after login was signed with the old token's secret", i+1)
+		}
+	}
+
+	cfg, err := utils.LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIToken != fresh || cfg.SigningSecret != "" {
+		t.Errorf("saved token %q with secret %q, want the new token and no secret", cfg.APIToken, cfg.SigningSecret)
+	}
+}
+
+func TestAccountError(t *testing.T) {
+	isolate(t)
+	s := mock.NewServer()
+	t.Cleanup(s.Close)
+	token := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+	down := true
+	flaky := roundTripper(func(req *http.Request) (*http.Response, error) {
+		if down {
+			return &http.Response{StatusCode: http.StatusBadGateway, Body: io.NopCloser(strings.NewReader("bad gateway")), Request: req}, nil
+		}
+		return http.DefaultTransport.RoundTrip(req)
+	})
+	m := InitialModel(token, "", api.WithBaseURL(s.URL), api.WithHTTPTransport(flaky))
+
+	next, _ := m.Update(m.FetchAccount()())
+	m = next.(MainModel)
+	if m.State != StateError || !strings.Contains(m.ErrorMessage, "Error loading account") {
+		t.Fatalf("after a failed account load state = %s, message %q", m.State, m.ErrorMessage)
+	}
+
+	down = false
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	m = next.(MainModel)
+	if cmd == nil {
+		t.Fatal("leaving the error didn't retry the account")
+	}
+	next, _ = m.Update(cmd())
+	m = next.(MainModel)
+	if m.User == nil || m.User.Name != "Op" {
+		t.Errorf("user after retrying = %+v", m.User)
+	}
+
+	rejected := InitialModel("not-a-token", "", api.WithBaseURL(s.URL))
+	next, _ = rejected.Update(rejected.FetchAccount()())
+	if m := next.(MainModel); m.State != StateTokenInput || m.LoginError == "" {
+		t.Errorf("rejected token: state = %s, login error %q, want the token prompt", m.State, m.LoginError)
+	}
+}
+
+type roundTripper func(*http.Request) (*http.Response, error)
+
+func (f roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestReport(t *testing.T) {
+	s := mock.NewServer()
+	t.Cleanup(s.Close)
+	token := s.AddMember("Op", "op@example.com", api.RoleMember, 10)
+	rec := api.TraceDetailID{ID: 7, Name: "ALI", Address: "1 JALAN LAMA"}
+	s.AddRecord(rec)
+
+	m := newTestModel(t, s, token)
+	m.UserDetails = &rec
+	m.UserID = rec.ID
+	m.Withheld = []string{"mykad"}
+	m.State = StateTraceDetails
+
+	send := func(msg tea.KeyMsg) tea.Cmd {
+		t.Helper()
+		next, cmd := m.Update(msg)
+		m = next.(MainModel)
+		return cmd
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r")})
+	if m.State != StateReport || m.ReportRecord != 7 {
+		t.Fatalf("after r state = %s, reporting record %d", m.State, m.ReportRecord)
+	}
+	if slices.Contains(m.ReportFields, "mykad") {
+		t.Errorf("withheld field offered for reporting: %v", m.ReportFields)
+	}
+	address := slices.Index(m.ReportFields, "address")
+	for range address {
+		send(tea.KeyMsg{Type: tea.KeyDown})
+	}
+	send(tea.KeyMsg{Type: tea.KeySpace})
+
+	send(tea.KeyMsg{Type: tea.KeyEnter})
+	if m.State != StateReport || m.ReportError == "" {
+		t.Fatalf("empty comment: state = %s, error %q, want it refused", m.State, m.ReportError)
+	}
+	send(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("moved")})
+	cmd := send(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Fatal("submitting started no request")
+	}
+	next, _ := m.Update(cmd())
+	m = next.(MainModel)
+	if m.State != StateTraceDetails || !strings.Contains(m.ReportNotice, "report 1") {
+		t.Fatalf("after submitting state = %s, notice %q", m.State, m.ReportNotice)
+	}
+
+	reports, err := m.APIClient.MyReports()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != 1 || reports[0].RecordID != 7 || reports[0].Comment != "moved" || !slices.Equal(reports[0].Fields, []string{"address"}) {
+		t.Errorf("stored reports = %+v, want one on record 7 for the address saying \"moved\"", reports)
+	}
+}
@@ -0,0 +1,213 @@
+package ui
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/charmbracelet/x/ansi"
+)
+
+const (
+	buttonPrev   = "[ ‹ Prev ]"
+	buttonNext   = "[ Next › ]"
+	buttonBack   = "[ ← Back ]"
+	buttonAck    = "[ ✓ Acknowledge ]"
+	buttonReport = "[ ⚑ Report ]"
+	buttonSubmit = "[ ✓ Submit ]"
+)
+
+var buttonStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF69B4")).Bold(true)
+
+// buttons lists the on-screen buttons for the current state. Each one
+// stands in for a key the state already handles, see buttonKey.
+func (m MainModel) buttons() []string {
+	if !m.Mouse {
+		return nil
+	}
+	switch m.State {
+	case StateTraceNameResults, StateHistory, StateOnboarding:
+		return []string{buttonPrev, buttonNext, buttonBack}
+	case StateTraceDetails:
+		return []string{buttonPrev, buttonNext, buttonReport, buttonBack}
+	case StateReport:
+		return []string{buttonSubmit, buttonBack}
+	case StateHelp, StateHelpTopic, StateTraceNameInput, StateTraceNRICInput, StateError, StateAbout, StateUsage,
+		StateTeam, StateTeamTokens, StateTeamLimitInput, StateTeamNewToken, StateTokens, StateJustification, StateApprovals, StateReports,
+		StateStepUp, StateDenied, StateAUP, StateConfirm:
+		return []string{buttonBack}
+	case StateAnomaly:
+		return []string{buttonAck}
+	case StateTokenInput:
+		if m.APIToken != "" {
+			return []string{buttonBack}
+		}
+	}
+	return nil
+}
+
+func (m MainModel) buttonKey(button string) tea.KeyMsg {
+	switch button {
+	case buttonPrev:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'p'}}
+	case buttonNext:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'n'}}
+	case buttonAck, buttonSubmit:
+		return tea.KeyMsg{Type: tea.KeyEnter}
+	case buttonReport:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'r'}}
+	}
+	switch m.State {
+	// Lists treat esc as quit, so they go back with m.
+	case StateTraceNameResults, StateHistory, StateTeam, StateTokens, StateApprovals, StateReports:
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'m'}}
+	}
+	return tea.KeyMsg{Type: tea.KeyEsc}
+}
+
+func (m MainModel) buttonBar() string {
+	buttons := m.buttons()
+	if len(buttons) == 0 {
+		return ""
+	}
+	rendered := make([]string, len(buttons))
+	for i, b := range buttons {
+		rendered[i] = buttonStyle.Render(b)
+	}
+	return "\n" + strings.Join(rendered, "  ")
+}
+
+// listHeight leaves a line under lists for the button bar.
+func (m MainModel) listHeight() int {
+	if m.Mouse {
+		return m.Height - 1
+	}
+	return m.Height
+}
+
+// handleMouse maps clicks and taps onto the key presses each screen
+// already understands. The wheel scrolls; a tap on a menu entry opens it,
+// and in other lists the first tap selects and a second one opens, so a
+// stray touch never revokes a token.
+func (m MainModel) handleMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case msg.Button == tea.MouseButtonWheelUp || msg.Button == tea.MouseButtonWheelDown:
+		return m.scroll(msg), nil
+	case msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionPress:
+		return m, nil
+	}
+
+	for _, b := range m.buttons() {
+		if m.clicked(msg, b) {
+			return m.Update(m.buttonKey(b))
+		}
+	}
+
+	y := msg.Y - m.Doc.GetMarginTop()
+	switch m.State {
+	case StateMainMenu:
+		if i, ok := itemAt(m.Menu, y); ok {
+			m.Menu.Select(i)
+			return m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		}
+	case StateHelp:
+		// The list sits under the search input and a blank line.
+		if i, ok := itemAt(m.HelpList, y-2); ok {
+			if i != m.HelpList.Index() {
+				m.HelpList.Select(i)
+				return m, nil
+			}
+			return m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		}
+	case StateTraceNameResults, StateHistory, StateTeam, StateTeamTokens, StateTokens, StateApprovals, StateReports:
+		if i, ok := itemAt(m.List, y); ok {
+			if i != m.List.Index() {
+				m.List.Select(i)
+				return m, nil
+			}
+			return m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+		}
+	}
+	return m, nil
+}
+
+func (m MainModel) scroll(msg tea.MouseMsg) MainModel {
+	up := msg.Button == tea.MouseButtonWheelUp
+	switch m.State {
+	case StateMainMenu:
+		if up {
+			m.Menu.CursorUp()
+		} else {
+			m.Menu.CursorDown()
+		}
+	case StateTraceNameResults, StateHistory, StateTeam, StateTeamTokens, StateTokens, StateApprovals, StateReports:
+		if up {
+			m.List.CursorUp()
+		} else {
+			m.List.CursorDown()
+		}
+	case StateHelp:
+		if up {
+			m.HelpList.CursorUp()
+		} else {
+			m.HelpList.CursorDown()
+		}
+	case StateTraceDetails:
+		m.Details, _ = m.Details.Update(msg)
+	case StateHelpTopic:
+		m.HelpView, _ = m.HelpView.Update(msg)
+	case StateAUP:
+		m.AUPView, _ = m.AUPView.Update(msg)
+	}
+	return m
+}
+
+// clicked reports whether msg landed on label in the current view. Views
+// are centred and wrapped differently per layout, so rather than track
+// positions the rendered output is searched for the label.
+func (m MainModel) clicked(msg tea.MouseMsg, label string) bool {
+	lines := strings.Split(ansi.Strip(m.View()), "\n")
+	if msg.Y < 0 || msg.Y >= len(lines) {
+		return false
+	}
+	line := lines[msg.Y]
+	width := ansi.StringWidth(label)
+	for offset := 0; ; {
+		i := strings.Index(line[offset:], label)
+		if i < 0 {
+			return false
+		}
+		start := ansi.StringWidth(line[:offset+i])
+		if msg.X >= start && msg.X < start+width {
+			return true
+		}
+		offset += i + len(label)
+	}
+}
+
+// itemAt maps row y of a list's view to the index of the item drawn there.
+// It mirrors list.Model.View: title bar, status bar, then items laid out by
+// the default delegate.
+func itemAt(l list.Model, y int) (int, bool) {
+	if l.FilterState() == list.Filtering {
+		return 0, false
+	}
+	if l.ShowTitle() || (l.ShowFilter() && l.FilteringEnabled()) {
+		y -= lipgloss.Height(l.Styles.TitleBar.Render(l.Title))
+	}
+	if l.ShowStatusBar() {
+		y -= lipgloss.Height(l.Styles.StatusBar.Render(""))
+	}
+
+	d := list.NewDefaultDelegate()
+	row := d.Height() + d.Spacing()
+	if y < 0 || y%row >= d.Height() || y/row >= l.Paginator.PerPage {
+		return 0, false
+	}
+	i := l.Paginator.Page*l.Paginator.PerPage + y/row
+	if i >= len(l.VisibleItems()) {
+		return 0, false
+	}
+	return i, true
+}
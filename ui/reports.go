@@ -0,0 +1,158 @@
+package ui
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"synthera/api"
+	"synthera/audit"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// openReport starts an inaccuracy report on the record on screen. Only the
+// fields the user was shown can be reported.
+func (m MainModel) openReport() MainModel {
+	if m.UserDetails == nil {
+		return m
+	}
+	m.ReportRecord = m.UserDetails.ID
+	m.ReportFields = slices.DeleteFunc(api.RecordFields(), func(name string) bool {
+		return slices.Contains(m.Withheld, name)
+	})
+	m.ReportChosen = map[string]bool{}
+	m.ReportCursor = 0
+	m.ReportError = ""
+	m.ReportInput.Reset()
+	m.ReportInput.Blur()
+	m.State = StateReport
+	return m
+}
+
+// updateReport handles keys on the report form. ↑/↓ move between the
+// fields and the comment below them, space ticks a field and enter
+// submits.
+func (m MainModel) updateReport(msg tea.KeyMsg) (MainModel, tea.Cmd) {
+	var cmd tea.Cmd
+	onComment := m.ReportCursor == len(m.ReportFields)
+	switch {
+	case msg.Type == tea.KeyEsc:
+		m.State = StateTraceDetails
+		return m, nil
+	case msg.Type == tea.KeyEnter:
+		return m.submitReport()
+	case msg.Type == tea.KeyUp || msg.Type == tea.KeyShiftTab:
+		m.ReportCursor = max(0, m.ReportCursor-1)
+	case msg.Type == tea.KeyDown || msg.Type == tea.KeyTab:
+		m.ReportCursor = min(len(m.ReportFields), m.ReportCursor+1)
+	case onComment:
+		m.ReportInput, cmd = m.ReportInput.Update(msg)
+		return m, cmd
+	case msg.Type == tea.KeySpace || msg.String() == "x":
+		name := m.ReportFields[m.ReportCursor]
+		m.ReportChosen[name] = !m.ReportChosen[name]
+		m.ReportError = ""
+	}
+	if m.ReportCursor == len(m.ReportFields) {
+		cmd = m.ReportInput.Focus()
+	} else {
+		m.ReportInput.Blur()
+	}
+	return m, cmd
+}
+
+func (m MainModel) submitReport() (MainModel, tea.Cmd) {
+	var fields []string
+	for _, name := range m.ReportFields {
+		if m.ReportChosen[name] {
+			fields = append(fields, name)
+		}
+	}
+	comment := strings.TrimSpace(m.ReportInput.Value())
+	switch {
+	case len(fields) == 0:
+		m.ReportError = "Tick the fields that are wrong or out of date"
+		return m, nil
+	case comment == "":
+		m.ReportCursor = len(m.ReportFields)
+		m.ReportError = "Say what is wrong, for example that the person has moved"
+		return m, m.ReportInput.Focus()
+	}
+	m.State = StateLoading
+	return m, m.SubmitReport(api.SubmitReportRequest{
+		RecordID: m.ReportRecord,
+		Fields:   fields,
+		Comment:  comment,
+	})
+}
+
+// reported records a submitted report and goes back to the record.
+func (m MainModel) reported(r api.Report) MainModel {
+	m.audit(audit.Event{
+		Action:   audit.ActionReport,
+		RecordID: r.RecordID,
+		Detail:   fmt.Sprintf("report %d: %s", r.ID, strings.Join(r.Fields, ", ")),
+	})
+	m.ReportInput.Reset()
+	m.ReportNotice = fmt.Sprintf("Reported as inaccurate (report %d), follow it under My Reports.", r.ID)
+	m.State = StateTraceDetails
+	return m.refreshDetails()
+}
+
+func (m MainModel) reportView() string {
+	var values map[string]any
+	if m.UserDetails != nil {
+		data, _ := json.Marshal(m.UserDetails)
+		json.Unmarshal(data, &values)
+	}
+
+	labelWidth := 0
+	for _, name := range m.ReportFields {
+		labelWidth = max(labelWidth, len(fieldLabel(name)))
+	}
+	inner := m.textWidth() - m.boxStyle().GetHorizontalFrameSize()
+	rows := make([]string, len(m.ReportFields))
+	for i, name := range m.ReportFields {
+		cursor, tick := "  ", "[ ]"
+		if i == m.ReportCursor {
+			cursor = "> "
+		}
+		if m.ReportChosen[name] {
+			tick = "[x]"
+		}
+		value, _ := values[name].(string)
+		row := fmt.Sprintf("%s%s %-*s  %s", cursor, tick, labelWidth, fieldLabel(name), value)
+		rows[i] = ansi.Truncate(row, inner, "…")
+	}
+
+	var s strings.Builder
+	s.WriteString(labelStyle.Render(fmt.Sprintf("Report record %d as inaccurate", m.ReportRecord)))
+	s.WriteString("\n")
+	s.WriteString(m.box(strings.Join(rows, "\n")))
+	s.WriteString("\n")
+	s.WriteString(inputStyle.Render(m.ReportInput.View()))
+	if m.ReportError != "" {
+		s.WriteString("\n" + m.errorText(m.ReportError))
+	}
+	s.WriteString(m.help("\n↑/↓ move · space ticks a field · the last line is your comment · enter to submit, esc to cancel"))
+	return s.String()
+}
+
+func (m MainModel) newReportList(reports []api.Report) list.Model {
+	items := make([]list.Item, len(reports))
+	for i, r := range reports {
+		items[i] = reportItem{report: r}
+	}
+
+	reportKeys := newReportKeyMap()
+	l := newList(items, m.Width, m.listHeight())
+	l.Title = "My Reports"
+	l.AdditionalShortHelpKeys = func() []key.Binding {
+		return []key.Binding{reportKeys.refresh}
+	}
+	return l
+}
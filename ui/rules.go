@@ -0,0 +1,100 @@
+package ui
+
+import (
+	"synthera/api"
+	"synthera/audit"
+	"synthera/rules"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// recentWindow is how much of the audit log is kept in memory for the rate
+// rule and the anomaly detector.
+const recentWindow = 24 * time.Hour
+
+// WithLocalRules gives the TUI rules loaded from a signed file, for a
+// backend that serves none. Rules the server returns always win. A non-nil
+// err is a file that failed to load, which then denies every lookup.
+func (m MainModel) WithLocalRules(r api.Rules, err error) MainModel {
+	m.LocalRules, m.LocalRulesErr = &r, err
+	if err != nil {
+		m.LocalRules = nil
+	}
+	return m
+}
+
+// lookup runs next, a trace call of the given audit.Lookup kind, once the
+// acceptable-use policy is accepted and if the usage rules allow it, asking
+// for a justification first when they require one. Anything but a name
+// search then goes through withStepUp.
+func (m MainModel) lookup(next tea.Cmd, kind string) (MainModel, tea.Cmd) {
+	if !m.aupAccepted() {
+		return m.lockedAUP()
+	}
+	if v := m.checkRules(); v != nil {
+		return m.deny(v)
+	}
+	if m.Rules.JustificationRequired && m.Reason == "" {
+		m.JustifyNext = next
+		m.JustifyKind = kind
+		m.JustificationInput.Reset()
+		m.JustificationInput.Focus()
+		m.State = StateJustification
+		return m, nil
+	}
+
+	next = m.logged(next, kind)
+	if kind != audit.LookupName {
+		return m.withStepUp(next)
+	}
+	m.State = StateLoading
+	return m, next
+}
+
+// checkRules reports the rule a lookup now would break. Until the rules
+// have loaded every lookup is refused.
+func (m MainModel) checkRules() *rules.Violation {
+	switch {
+	case m.RulesErr != nil:
+		return &rules.Violation{Rule: rules.RuleLoaded, Message: "The usage rules could not be loaded: " + m.RulesErr.Error()}
+	case m.Rules == nil:
+		return &rules.Violation{Rule: rules.RuleLoaded, Message: "The usage rules haven't loaded yet, try again in a moment."}
+	}
+
+	now := time.Now()
+	recent := 0
+	if m.Rules.MaxLookupsPerHour > 0 {
+		if err := m.Recent.Err(); err != nil {
+			return &rules.Violation{Rule: rules.RuleRate, Message: "Recent lookups can't be counted, the audit log is unreadable: " + err.Error()}
+		}
+		for _, e := range m.Recent.Since(now.Add(-time.Hour)) {
+			if e.Action == audit.ActionLookup && m.User != nil && e.UserID == m.User.ID {
+				recent++
+			}
+		}
+	}
+	return rules.Check(*m.Rules, now, recent)
+}
+
+// deny shows the rule a lookup broke and records the denial. Rules from the
+// server are fetched again, in case they failed to load.
+func (m MainModel) deny(v *rules.Violation) (MainModel, tea.Cmd) {
+	m.audit(audit.Event{Action: audit.ActionDenied, Detail: v.Error()})
+	m.Denial = v
+	m.State = StateDenied
+	if m.Rules != nil {
+		return m, nil
+	}
+	return m, m.FetchRules()
+}
+
+// logged records the lookup in the audit log as it runs; the rate rule
+// and the anomaly detector count these.
+func (m MainModel) logged(next tea.Cmd, kind string) tea.Cmd {
+	reason := m.Reason
+	return func() tea.Msg {
+		m.audit(audit.Event{Action: audit.ActionLookup, Kind: kind, Detail: reason})
+		return next()
+	}
+}
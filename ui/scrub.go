@@ -0,0 +1,63 @@
+package ui
+
+import (
+	"synthera/api"
+	"synthera/usage"
+	"time"
+)
+
+// Scrub zeroes the records, queries and tokens the model holds. Go strings
+// are immutable, so this clears the structs and inputs that reference them
+// and leaves the backing memory for the garbage collector to reclaim.
+func (m *MainModel) Scrub() {
+	if m.UserDetails != nil {
+		*m.UserDetails = api.TraceDetailID{}
+		m.UserDetails = nil
+	}
+	if m.Relations != nil {
+		*m.Relations = api.TraceRelationsItem{}
+		m.Relations = nil
+	}
+	if m.Usage != nil {
+		*m.Usage = usage.Report{}
+		m.Usage = nil
+	}
+	if m.User != nil {
+		*m.User = api.User{}
+		m.User = nil
+	}
+	if m.SelectedMember != nil {
+		*m.SelectedMember = api.TeamMember{}
+		m.SelectedMember = nil
+	}
+	m.UserID = 0
+	m.Withheld = nil
+	m.FieldPolicy = nil
+	m.IssuedToken = ""
+	m.APIToken = ""
+	m.APIClient = nil
+	m.ErrorMessage = ""
+	m.LoginError = ""
+
+	m.TokenInput.Reset()
+	m.NameInput.Reset()
+	m.NRICInput.Reset()
+	m.LimitInput.Reset()
+	m.JustificationInput.Reset()
+	m.PendingLookup = nil
+	m.Reason = ""
+	m.JustifyNext = nil
+	m.Denial = nil
+	m.Anomalies = nil
+	m.ReportInput.Reset()
+	m.ReportChosen = nil
+	m.ReportNotice = ""
+	m.StepUpInput.Reset()
+	m.StepUpNext = nil
+	m.StepUpUntil = time.Time{}
+	m.HelpInput.Reset()
+	m.List.SetItems(nil)
+	m.Details.SetContent("")
+	m.HelpView.SetContent("")
+	m.AUPView.SetContent("")
+}
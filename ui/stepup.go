@@ -0,0 +1,88 @@
+package ui
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// stepUpRequired reports whether detail lookups need a one-time code,
+// either because the user enrolled an authenticator app or because the
+// policy makes everyone use one.
+func (m MainModel) stepUpRequired() bool {
+	return (m.User != nil && m.User.TOTPEnabled) || (m.Policy != nil && m.Policy.StepUpRequired)
+}
+
+// steppedUp reports whether a code was verified recently enough that the
+// backend still honours it.
+func (m MainModel) steppedUp() bool {
+	return time.Now().Before(m.StepUpUntil)
+}
+
+// masked reports whether sensitive fields in result lists are hidden.
+func (m MainModel) masked() bool {
+	return m.stepUpRequired() && !m.steppedUp()
+}
+
+// withStepUp runs next, first asking for a one-time code if the grace
+// window has run out. With a nil next it only asks for the code, which
+// reveals masked fields.
+func (m MainModel) withStepUp(next tea.Cmd) (MainModel, tea.Cmd) {
+	switch {
+	case !m.stepUpRequired() || m.steppedUp():
+		if next != nil {
+			m.State = StateLoading
+		}
+		return m, next
+	case m.User != nil && !m.User.TOTPEnabled:
+		m.State = StateError
+		m.ErrorMessage = "Your organisation requires two-step verification for this lookup. Run synthera totp enroll to set it up."
+		return m, nil
+	}
+
+	m.StepUpNext = next
+	m.StepUpReturn = m.State
+	m.StepUpError = ""
+	m.StepUpInput.Reset()
+	m.StepUpInput.Focus()
+	m.State = StateStepUp
+	return m, nil
+}
+
+// unmask shows the fields masked in the current result list.
+func (m MainModel) unmask() MainModel {
+	items := m.List.Items()
+	changed := false
+	for i, item := range items {
+		if n, ok := item.(nameItem); ok && n.masked {
+			n.masked = false
+			items[i] = n
+			changed = true
+		}
+	}
+	if changed {
+		m.List.SetItems(items)
+		m.List.AdditionalShortHelpKeys = nameListKeys(false)
+	}
+	return m
+}
+
+// digitsOnly keeps the code input to what an authenticator app shows.
+func digitsOnly(s string) error {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return errors.New("digits only")
+		}
+	}
+	return nil
+}
+
+// maskMykad keeps the last four digits, enough to tell results apart.
+func maskMykad(mykad string) string {
+	if len(mykad) <= 4 {
+		return strings.Repeat("•", len(mykad))
+	}
+	return strings.Repeat("•", len(mykad)-4) + mykad[len(mykad)-4:]
+}
@@ -1,46 +1,168 @@
 package ui
 
 import (
+	"synthera/anomaly"
 	"synthera/api"
+	"synthera/audit"
+	"synthera/help"
+	"synthera/rules"
+	"synthera/usage"
+	"synthera/utils"
+	"synthera/version"
+	"time"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
 	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 )
 
 type MainModel struct {
-	State        AppState
-	TokenInput   textinput.Model
-	NameInput    textinput.Model
-	NRICInput    textinput.Model
-	UserDetails  *api.TraceDetailID
-	UserID       int
-	Relations    *api.TraceRelationsItem
-	APIToken     string
-	ErrorMessage string
-	APIClient    *api.Client
-	Offset       int
-	Page         int
+	State      AppState
+	TokenInput textinput.Model
+	NameInput  textinput.Model
+	NRICInput  textinput.Model
+	LimitInput textinput.Model
+	// JustificationInput collects the reason sent with an approval
+	// request for PendingLookup.
+	JustificationInput textinput.Model
+	PendingLookup      *api.SubmitApprovalRequest
+	UserDetails        *api.TraceDetailID
+	UserID             int
+	Relations          *api.TraceRelationsItem
+	Usage              *usage.Report
+	User               *api.User
+	SelectedMember     *api.TeamMember
+	IssuedToken        string
+	BuildInfo          version.Info
+	APIToken           string
+	ErrorMessage       string
+	LoginError         string
+	APIClient          *api.Client
+	ClientOpts         []api.Option
+	Offset             int
+	Page               int
 
 	Spinner spinner.Model
 	Doc     lipgloss.Style
 	List    list.Model
 	Menu    list.Model
-	Width   int
-	Height  int
-	Logo    string
+	Details viewport.Model
+
+	HelpInput      textinput.Model
+	HelpList       list.Model
+	HelpView       viewport.Model
+	HelpTopic      string
+	HelpReturn     AppState
+	OnboardingPage int
+	Onboarded      bool
+
+	// Session identifies this run in the watermark and the audit log, and
+	// Recent holds the log's latest events for the checks made on each
+	// lookup.
+	Session   string
+	Recent    *audit.Recent
+	Watermark bool
+	Policy    *api.Policy
+	// FieldPolicy is what the user's role may not see, and Withheld the
+	// fields it kept out of the record on screen.
+	FieldPolicy *api.FieldPolicy
+	Withheld    []string
+
+	// Rules are the acceptable-use rules lookups are checked against, from
+	// the server or, when it has none, LocalRules from a signed file.
+	// RulesErr and LocalRulesErr are why they failed to load. Denial is the
+	// rule the last refused lookup broke.
+	Rules         *api.Rules
+	RulesErr      error
+	LocalRules    *api.Rules
+	LocalRulesErr error
+	Denial        *rules.Violation
+	// Reason is the justification given for the current search, and
+	// JustifyNext the lookup, of JustifyKind, waiting on one.
+	Reason      string
+	JustifyNext tea.Cmd
+	JustifyKind string
+
+	// Anomalies are the unusual patterns waiting to be acknowledged before
+	// the AnomalyReturn screen. Thresholds tune the detector within the
+	// org policy's limits, and ReportAnomalies sends what it finds to the
+	// org admins even if the policy doesn't.
+	Anomalies       []anomaly.Finding
+	AnomalyReturn   AppState
+	Thresholds      anomaly.Thresholds
+	ReportAnomalies bool
+
+	// ReportRecord is the record being reported as inaccurate, ReportFields
+	// the fields that can be ticked and ReportChosen those that are.
+	// ReportCursor is the row selected, len(ReportFields) being the comment.
+	// ReportNotice confirms a report under the record it was made on.
+	ReportRecord int
+	ReportFields []string
+	ReportChosen map[string]bool
+	ReportCursor int
+	ReportInput  textinput.Model
+	ReportError  string
+	ReportNotice string
+
+	// AUP is the acceptable-use policy in force, from the backend or built
+	// in, and AcceptedAUP the acceptances recorded on this machine. Tracing
+	// stays locked until the user has accepted AUP's version. AUPLocal is
+	// set for backends that don't track acceptance.
+	AUP         *api.AUP
+	AUPErr      error
+	AUPLocal    bool
+	AcceptedAUP []utils.AUPAcceptance
+	AUPView     viewport.Model
+
+	// ConfirmNext is the action waiting on a y from ConfirmPrompt. Any
+	// other key returns to ConfirmReturn.
+	ConfirmPrompt string
+	ConfirmNext   tea.Cmd
+	ConfirmReturn AppState
+
+	// StepUpNext is the lookup waiting on a one-time code; it runs once
+	// the code is verified, and StepUpUntil ends the grace window that
+	// buys. Cancelling returns to StepUpReturn.
+	StepUpInput  textinput.Model
+	StepUpNext   tea.Cmd
+	StepUpUntil  time.Time
+	StepUpReturn AppState
+	StepUpError  string
+
+	Layout layout
+	Mouse  bool
+	Width  int
+	Height int
+	Logo   string
+}
+
+// field is one label: value row of a details box.
+type field struct {
+	Label string
+	Value string
 }
 
+type helpItem struct {
+	topic help.Topic
+}
+
+// nameItem is one name result. withheld means the field policy hides the
+// Mykad from the user's role, so it was dropped on arrival.
 type nameItem struct {
-	item api.TraceNameItem
+	item     api.TraceNameItem
+	masked   bool
+	withheld bool
 }
 
 type listKeyMap struct {
 	toggleNextPage     key.Binding
 	togglePreviousPage key.Binding
 	toggleMenu         key.Binding
+	reveal             key.Binding
 }
 
 type menuItem struct {
@@ -51,3 +173,55 @@ type menuItem struct {
 type historyItem struct {
 	item api.HistoryItem
 }
+
+type memberItem struct {
+	member api.TeamMember
+}
+
+type tokenItem struct {
+	info api.TokenInfo
+}
+
+type reportItem struct {
+	report api.Report
+}
+
+// approvalItem is one of the user's own requests, or, when mine is false,
+// another operator's request waiting for their decision.
+// approvalItem is one approval request. withheld hides its Mykad, which
+// is still needed to run the lookup, from a role that may not see it.
+type approvalItem struct {
+	approval api.Approval
+	mine     bool
+	withheld bool
+}
+
+type teamKeyMap struct {
+	issueToken    key.Binding
+	revokeToken   key.Binding
+	setLimit      key.Binding
+	toggleSuspend key.Binding
+}
+
+type approvalKeyMap struct {
+	run     key.Binding
+	approve key.Binding
+	reject  key.Binding
+	refresh key.Binding
+}
+
+type reportKeyMap struct {
+	refresh key.Binding
+}
+
+type tokenKeyMap struct {
+	rotate       key.Binding
+	revoke       key.Binding
+	revokeDevice key.Binding
+}
+
+// UpdateAvailableMsg is sent by main when the startup check finds a newer
+// release.
+type UpdateAvailableMsg struct {
+	Version string
+}
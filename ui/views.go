@@ -2,17 +2,19 @@ package ui
 
 import (
 	"fmt"
+	"slices"
 	"strings"
+	"synthera/usage"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true).Width(60).Align(lipgloss.Center).PaddingBottom(5)
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FF0000")).Bold(true).Align(lipgloss.Center).PaddingBottom(5)
 	labelStyle = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("#87CEEB"))
 	valueStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("#FFFFFF"))
 	boxStyle   = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(lipgloss.Color("#FF69B4")).Margin(1, 1).Padding(1, 2)
-	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).Width(60).AlignHorizontal(lipgloss.Center)
+	helpStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("241")).AlignHorizontal(lipgloss.Center)
 	inputStyle = lipgloss.NewStyle().Align(lipgloss.Center)
 )
 
@@ -21,68 +23,154 @@ func (m MainModel) View() string {
 
 	switch m.State {
 	case StateTokenInput:
-		s.WriteString(m.Logo)
+		s.WriteString(m.logo())
 		s.WriteString(inputStyle.Render(m.TokenInput.View()))
-		s.WriteString(helpStyle.Render("\nPress enter to continue"))
+		if m.LoginError != "" {
+			s.WriteString("\n" + m.errorText(m.LoginError))
+		}
+		help := "\nPress enter to continue"
+		if m.APIToken != "" {
+			help += ", esc to keep your current token"
+		}
+		s.WriteString(m.help(help))
 	case StateTraceNameInput:
-		s.WriteString(m.Logo)
+		s.WriteString(m.logo())
 		s.WriteString(inputStyle.Render(m.NameInput.View()))
 	case StateTraceNameResults:
-		return m.Doc.Render(m.List.View())
+		return m.Doc.Render(m.watermark(m.List.View() + m.buttonBar()))
 	case StateTraceDetails:
-		details := m.UserDetails
-		s.WriteString(m.Logo)
-
-		fields := []struct {
-			Label string
-			Value string
-		}{
-			{"Name", details.Name},
-			{"Mykad", details.Mykad},
-			{"Address", details.Address},
-			{"Gender", details.Gender},
-			{"Mobile", details.Mobile},
-			{"Phone", details.Phone},
-			{"Race", details.Race},
-			{"Religion", details.Religion},
-			{"Income", details.Income},
-		}
-
-		var rows []string
-		for _, f := range fields {
-			if f.Value == "" {
-				continue
-			}
-
-			rows = append(rows, fmt.Sprintf("%s: %s", labelStyle.Render(f.Label), valueStyle.Render(f.Value)))
-		}
-
-		if m.Relations != nil {
-			rows = append(rows, fmt.Sprintf("%s: %s", labelStyle.Render("Relation"), valueStyle.Render(m.Relations.Relation)))
-		}
-
-		data := strings.Join(rows, "\n")
-		s.WriteString(boxStyle.Render(data))
-		s.WriteString(helpStyle.Render("\nPress [n] to view next relationships, [p] for previous, and any other key to return to main menu."))
+		s.WriteString(m.logo())
+		s.WriteString(m.Details.View())
+		s.WriteString(m.detailsHelp())
 	case StateLoading:
 		s.WriteString(fmt.Sprintf("%s Loading...", m.Spinner.View()))
 	case StateError:
-		s.WriteString(errorStyle.Render("Error: " + m.ErrorMessage))
-		s.WriteString(helpStyle.Render("\nPress any key to return to main menu"))
+		s.WriteString(m.errorText("Error: " + m.ErrorMessage))
+		s.WriteString(m.help("\nPress any key to return to main menu"))
+	case StateUpdateRequired:
+		s.WriteString(m.errorText("Please update the CLI"))
+		s.WriteString(valueStyle.Width(m.textWidth()).Render("The backend has changed and this version of synthera can no longer talk to it safely. Download the latest release and try again.\n\n" + m.ErrorMessage))
+		s.WriteString(m.help("\nPress any key to quit"))
+	case StateAbout:
+		info := m.BuildInfo
+		fields := []field{
+			{"Version", info.Short()},
+			{"Built", info.BuildDate},
+			{"Go", info.GoVersion},
+			{"Platform", info.OS + "/" + info.Arch},
+			{"Backend", info.BaseURL},
+			{"Profile", info.Profile},
+		}
+		fields = slices.DeleteFunc(fields, func(f field) bool { return f.Value == "" })
+		s.WriteString(m.box(fieldRows(fields, m.textWidth()-m.boxStyle().GetHorizontalFrameSize())))
+		s.WriteString(m.help("\nPress any key to return to main menu"))
 	case StateMainMenu:
 		return m.Doc.Render(m.Menu.View())
 	case StateTraceNRICInput:
-		s.WriteString(m.Logo)
+		s.WriteString(m.logo())
 		s.WriteString(inputStyle.Render(m.NRICInput.View()))
 	case StateHistory:
-		return m.Doc.Render(m.List.View())
+		return m.Doc.Render(m.watermark(m.List.View() + m.buttonBar()))
+	case StateUsage:
+		if m.Usage == nil {
+			break
+		}
+		var table strings.Builder
+		usage.Write(&table, *m.Usage, usage.FormatTable)
+		s.WriteString(m.box(strings.TrimRight(table.String(), "\n")))
+		s.WriteString(m.help("\nPress any key to return to main menu"))
+	case StateApprovals, StateReports:
+		return m.Doc.Render(m.watermark(m.List.View() + m.buttonBar()))
+	case StateReport:
+		s.WriteString(m.reportView())
+	case StateJustification:
+		s.WriteString(m.logo())
+		if m.PendingLookup == nil {
+			s.WriteString(labelStyle.Render("Your organisation asks why you are searching"))
+			s.WriteString("\n\n")
+			s.WriteString(inputStyle.Render(m.JustificationInput.View()))
+			s.WriteString(m.help("\nThe reason is kept in the audit log with each lookup in this search. Press enter to continue, esc to cancel"))
+			break
+		}
+		s.WriteString(labelStyle.Render("This lookup needs a supervisor's approval"))
+		s.WriteString("\n\n")
+		s.WriteString(inputStyle.Render(m.JustificationInput.View()))
+		s.WriteString(m.help("\nSay why you need it. Once approved it appears under Approvals, ready to run. Press enter to submit, esc to cancel"))
+	case StateAnomaly:
+		s.WriteString(m.errorText("Unusual activity"))
+		var rows []string
+		for _, f := range m.Anomalies {
+			rows = append(rows, "• "+f.Message)
+		}
+		s.WriteString(m.box(valueStyle.Width(m.textWidth() - m.boxStyle().GetHorizontalFrameSize()).Render(strings.Join(rows, "\n"))))
+		note := "This has been recorded in the audit log."
+		if m.reportAnomalies() {
+			note = "This has been recorded in the audit log and reported to your organisation."
+		}
+		s.WriteString(m.help("\n" + note + " Make sure each lookup has a lawful purpose. Press enter to acknowledge and continue"))
+	case StateDenied:
+		if m.Denial == nil {
+			break
+		}
+		s.WriteString(m.errorText("Lookup not permitted"))
+		fields := []field{
+			{"Rule", m.Denial.Rule},
+			{"Why", m.Denial.Message},
+		}
+		s.WriteString(m.box(fieldRows(fields, m.textWidth()-m.boxStyle().GetHorizontalFrameSize())))
+		s.WriteString(m.help("\nThe refusal has been recorded in the audit log. Press any key to return to main menu"))
+	case StateStepUp:
+		s.WriteString(m.logo())
+		s.WriteString(labelStyle.Render("Enter the code from your authenticator app"))
+		s.WriteString("\n\n")
+		s.WriteString(inputStyle.Render(m.StepUpInput.View()))
+		if m.StepUpError != "" {
+			s.WriteString("\n" + m.errorText(m.StepUpError))
+		}
+		s.WriteString(m.help("\nPress enter to verify, esc to cancel"))
+	case StateTeam, StateTeamTokens, StateTokens:
+		return m.Doc.Render(m.List.View() + m.buttonBar())
+	case StateConfirm:
+		s.WriteString(m.logo())
+		s.WriteString(labelStyle.Render(m.ConfirmPrompt))
+		s.WriteString(m.help("\nPress y to confirm, any other key to cancel"))
+	case StateHelp:
+		return m.Doc.Render(inputStyle.Render(m.HelpInput.View()) + "\n\n" + m.HelpList.View() + m.help("\nType to search · ↑/↓ choose · enter to read · esc to go back") + m.buttonBar())
+	case StateHelpTopic:
+		s.WriteString(m.HelpView.View())
+		s.WriteString(m.topicHelp())
+	case StateAUP:
+		s.WriteString(m.AUPView.View())
+		s.WriteString(m.aupHelp())
+	case StateOnboarding:
+		s.WriteString(m.onboardingView())
+	case StateTeamLimitInput:
+		if m.SelectedMember == nil {
+			break
+		}
+		s.WriteString(labelStyle.Render(fmt.Sprintf("Spending limit for %s", m.SelectedMember.Name)))
+		s.WriteString("\n\n")
+		s.WriteString(inputStyle.Render(m.LimitInput.View()))
+		s.WriteString(m.help("\nPress enter to save, esc to cancel"))
+	case StateTeamNewToken:
+		if m.SelectedMember == nil {
+			break
+		}
+		rows := []string{
+			fmt.Sprintf("%s: %s", labelStyle.Render("Member"), valueStyle.Render(m.SelectedMember.Name)),
+			fmt.Sprintf("%s: %s", labelStyle.Render("Token"), valueStyle.Render(m.IssuedToken)),
+		}
+		s.WriteString(m.box(strings.Join(rows, "\n")))
+		s.WriteString(m.help("\nThis token is only shown once, hand it over now. Press any key to continue."))
 	default:
 		s.WriteString(fmt.Sprintf("State: %+v", m.State))
 	}
+	s.WriteString(m.buttonBar())
 	return lipgloss.Place(
 		m.Width, m.Height,
 		lipgloss.Center,
 		lipgloss.Center,
 		s.String(),
+		m.whitespace()...,
 	)
 }
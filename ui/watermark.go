@@ -0,0 +1,68 @@
+package ui
+
+import (
+	"fmt"
+	"synthera/audit"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var watermarkColor = lipgloss.AdaptiveColor{Light: "254", Dark: "236"}
+
+// watermarked reports whether the current screen shows records and should
+// carry the watermark. Until the policy has loaded it is assumed to be
+// required, so turning the watermark off locally never wins over an admin.
+func (m MainModel) watermarked() bool {
+	switch m.State {
+	case StateTraceDetails, StateTraceNameResults, StateHistory, StateApprovals, StateReport, StateReports:
+	default:
+		return false
+	}
+	return m.Watermark || m.Policy == nil || m.Policy.WatermarkRequired
+}
+
+func (m MainModel) watermarkText() string {
+	operator := "unknown operator"
+	if m.User != nil {
+		operator = m.User.Name
+	}
+	return fmt.Sprintf(" %s · session %s · %s ", operator, m.Session, time.Now().Format("2006-01-02 15:04"))
+}
+
+// whitespace fills the space lipgloss.Place leaves around a view with the
+// watermark, drawn faintly so the content stays readable.
+func (m MainModel) whitespace() []lipgloss.WhitespaceOption {
+	if !m.watermarked() {
+		return nil
+	}
+	return []lipgloss.WhitespaceOption{
+		lipgloss.WithWhitespaceChars(m.watermarkText()),
+		lipgloss.WithWhitespaceForeground(watermarkColor),
+	}
+}
+
+// watermark pads a full-screen view such as a list out to the screen size,
+// so the watermark shows beside and below it without moving anything.
+func (m MainModel) watermark(view string) string {
+	if !m.watermarked() {
+		return view
+	}
+	return lipgloss.Place(m.Width, m.Height, lipgloss.Left, lipgloss.Top, view, m.whitespace()...)
+}
+
+// audit appends e to the local audit log under this session, keeping it in
+// Recent too. A log that can't be written doesn't stop the operator
+// working; doctor reports it.
+func (m MainModel) audit(e audit.Event) {
+	e.Session = m.Session
+	if m.User != nil {
+		e.Operator = m.User.Name
+		e.UserID = m.User.ID
+	}
+	if m.Recent == nil {
+		audit.Append(e)
+		return
+	}
+	m.Recent.Append(e)
+}
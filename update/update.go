@@ -0,0 +1,347 @@
+// Package update finds, verifies and installs
+// new synthera releases
+package update
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"synthera/version"
+	"time"
+)
+
+const DefaultReleaseURL = "https://github.com/slrmyapi/synthera-cli/releases"
+
+// PublicKey is the base64 ed25519 key release binaries are signed with,
+// set by build.sh through -ldflags "-X synthera/update.PublicKey=...".
+var PublicKey = ""
+
+var (
+	ErrNoPublicKey   = errors.New("this build has no release signing key, update manually")
+	ErrBadChecksum   = errors.New("downloaded binary does not match its checksum")
+	ErrBadSignature  = errors.New("downloaded binary is not signed by the synthera release key")
+	ErrUnknownFormat = errors.New("unrecognised version string")
+	ErrNotNewer      = errors.New("release is not newer than the running version")
+)
+
+type Release struct {
+	Version string
+	Asset   string
+}
+
+type Updater struct {
+	// ReleaseURL serves <ReleaseURL>/latest/download/VERSION and the
+	// assets build.sh produces, next to <asset>.sha256 and <asset>.sig.
+	ReleaseURL string
+	PublicKey  ed25519.PublicKey
+	HTTPClient *http.Client
+	GOOS       string
+	GOARCH     string
+	// Current is the running version; Apply refuses anything not newer.
+	Current string
+}
+
+// New returns an updater for releaseURL. Downloads go through rt, which
+// should be the transport the API client uses so the configured proxy, CA
+// bundle and pins apply here too; nil means http.DefaultTransport.
+func New(releaseURL string, rt http.RoundTripper) (*Updater, error) {
+	if releaseURL == "" {
+		releaseURL = DefaultReleaseURL
+	}
+
+	var key ed25519.PublicKey
+	if PublicKey != "" {
+		raw, err := base64.StdEncoding.DecodeString(PublicKey)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid release signing key compiled into this build")
+		}
+		key = raw
+	}
+
+	return &Updater{
+		ReleaseURL: strings.TrimRight(releaseURL, "/"),
+		PublicKey:  key,
+		HTTPClient: &http.Client{Transport: rt, Timeout: 5 * time.Minute},
+		GOOS:       runtime.GOOS,
+		GOARCH:     runtime.GOARCH,
+		Current:    version.Version,
+	}, nil
+}
+
+// SignedMessage is what build.sh signs for each asset: the release
+// version, asset name and checksum together, so a validly signed binary
+// from an older release can't be passed off as the latest one.
+func SignedMessage(version, asset string, sum []byte) []byte {
+	return fmt.Appendf(nil, "synthera-release\n%s\n%s\n%x\n", version, asset, sum)
+}
+
+// AssetName mirrors the naming in build.sh, which follows `uname -m` so the
+// README's wget one-liners resolve to the same file.
+func AssetName(goos, goarch string) string {
+	arch := goarch
+	switch goarch {
+	case "amd64":
+		arch = "x86_64"
+	case "386":
+		arch = "i386"
+	case "arm64":
+		arch = "aarch64"
+	}
+
+	name := fmt.Sprintf("synthera-%s-%s", goos, arch)
+	if goos == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func (u *Updater) Latest(ctx context.Context) (Release, error) {
+	body, err := u.fetch(ctx, "VERSION", 64)
+	if err != nil {
+		return Release{}, err
+	}
+	v := strings.TrimSpace(string(body))
+	if _, err := parseVersion(v); err != nil {
+		return Release{}, fmt.Errorf("release server returned %q: %w", v, err)
+	}
+	return Release{
+		Version: v,
+		Asset:   AssetName(u.GOOS, u.GOARCH),
+	}, nil
+}
+
+// Download checks the signature over rel's version and checksum, then
+// fetches the asset into a temporary file in dir and verifies it against
+// that checksum. The caller owns the returned file.
+func (u *Updater) Download(ctx context.Context, rel Release, dir string) (string, error) {
+	if len(u.PublicKey) == 0 {
+		return "", ErrNoPublicKey
+	}
+
+	sumFile, err := u.fetch(ctx, rel.Asset+".sha256", 1024)
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(sumFile))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("%w: empty checksum file", ErrBadChecksum)
+	}
+	wantSum, err := hex.DecodeString(fields[0])
+	if err != nil || len(wantSum) != sha256.Size {
+		return "", fmt.Errorf("%w: malformed checksum file", ErrBadChecksum)
+	}
+
+	sig, err := u.fetch(ctx, rel.Asset+".sig", 1024)
+	if err != nil {
+		return "", err
+	}
+	if len(sig) != ed25519.SignatureSize {
+		if sig, err = base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err != nil {
+			return "", fmt.Errorf("%w: malformed signature file", ErrBadSignature)
+		}
+	}
+	if !ed25519.Verify(u.PublicKey, SignedMessage(rel.Version, rel.Asset, wantSum), sig) {
+		return "", ErrBadSignature
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url(rel.Asset), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := u.HTTPClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", rel.Asset, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to download %s: %s", rel.Asset, resp.Status)
+	}
+
+	tmp, err := os.CreateTemp(dir, ".synthera-update-*")
+	if err != nil {
+		return "", err
+	}
+	ok := false
+	defer func() {
+		if !ok {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(tmp, hash), resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", rel.Asset, err)
+	}
+	if !bytes.Equal(hash.Sum(nil), wantSum) {
+		return "", ErrBadChecksum
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+	if err := os.Chmod(tmp.Name(), 0755); err != nil {
+		return "", err
+	}
+	ok = true
+	return tmp.Name(), nil
+}
+
+// Apply downloads rel and swaps it in for the binary at exe, provided it
+// is newer than the running version.
+func (u *Updater) Apply(ctx context.Context, rel Release, exe string) error {
+	if !Newer(rel.Version, u.Current) {
+		return fmt.Errorf("%w: %s, running %s", ErrNotNewer, rel.Version, u.Current)
+	}
+	newPath, err := u.Download(ctx, rel, filepath.Dir(exe))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(newPath)
+	return Replace(exe, newPath)
+}
+
+// Replace moves newPath over exe in a single rename, so exe is always
+// either the old binary or the new one. The old binary is linked or
+// copied to exe.old first and left there, so a bad release can be rolled
+// back by hand.
+func Replace(exe, newPath string) error {
+	backup := exe + ".old"
+	os.Remove(backup)
+	if runtime.GOOS == "windows" {
+		return replaceRunning(exe, newPath, backup)
+	}
+
+	if err := os.Link(exe, backup); err != nil {
+		if err := copyFile(exe, backup); err != nil {
+			return fmt.Errorf("failed to back up current binary: %w", err)
+		}
+	}
+	if err := os.Rename(newPath, exe); err != nil {
+		return fmt.Errorf("failed to install update, current version left in place: %w", err)
+	}
+	return nil
+}
+
+// replaceRunning is Replace for Windows, which won't rename over the
+// running image but does let it be moved aside. The old binary is put
+// back if the new one can't be moved in.
+func replaceRunning(exe, newPath, backup string) error {
+	if err := os.Rename(exe, backup); err != nil {
+		return fmt.Errorf("failed to move current binary aside: %w", err)
+	}
+	if err := os.Rename(newPath, exe); err != nil {
+		if rbErr := os.Rename(backup, exe); rbErr != nil {
+			return fmt.Errorf("failed to install update (%v) and to roll back: %w", err, rbErr)
+		}
+		return fmt.Errorf("failed to install update, previous version restored: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0755)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(dst)
+		return err
+	}
+	return out.Close()
+}
+
+// Newer reports whether candidate is a later release than current. Dev
+// builds are older than every release.
+func Newer(candidate, current string) bool {
+	c, err := parseVersion(candidate)
+	if err != nil {
+		return false
+	}
+	cur, err := parseVersion(current)
+	if err != nil {
+		return true
+	}
+
+	for i := range 3 {
+		if c.parts[i] != cur.parts[i] {
+			return c.parts[i] > cur.parts[i]
+		}
+	}
+	// A pre-release sorts before the release it leads up to.
+	switch {
+	case c.pre == cur.pre:
+		return false
+	case c.pre == "":
+		return true
+	case cur.pre == "":
+		return false
+	}
+	return c.pre > cur.pre
+}
+
+type semver struct {
+	parts [3]int
+	pre   string
+}
+
+func parseVersion(v string) (semver, error) {
+	var sv semver
+	core, pre, _ := strings.Cut(strings.TrimPrefix(v, "v"), "-")
+	core, _, _ = strings.Cut(core, "+")
+	fields := strings.Split(core, ".")
+	if len(fields) != 3 {
+		return sv, ErrUnknownFormat
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 {
+			return sv, ErrUnknownFormat
+		}
+		sv.parts[i] = n
+	}
+	sv.pre = pre
+	return sv, nil
+}
+
+func (u *Updater) url(name string) string {
+	return u.ReleaseURL + "/latest/download/" + name
+}
+
+func (u *Updater) fetch(ctx context.Context, name string, limit int64) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.url(name), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := u.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", name, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", name, resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
@@ -0,0 +1,128 @@
+package update
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// release serves one signed release the way build.sh lays it out.
+func release(t *testing.T, priv ed25519.PrivateKey, signedVersion, servedVersion string, binary []byte) *httptest.Server {
+	t.Helper()
+	asset := AssetName("linux", "amd64")
+	sum := sha256.Sum256(binary)
+	files := map[string][]byte{
+		"VERSION":         []byte(servedVersion + "\n"),
+		asset:             binary,
+		asset + ".sha256": []byte(hex.EncodeToString(sum[:]) + "  " + asset + "\n"),
+		asset + ".sig":    ed25519.Sign(priv, SignedMessage(signedVersion, asset, sum[:])),
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[filepath.Base(r.URL.Path)]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func newTestUpdater(t *testing.T, url string, pub ed25519.PublicKey) *Updater {
+	t.Helper()
+	u, err := New(url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.PublicKey = pub
+	u.GOOS, u.GOARCH = "linux", "amd64"
+	u.Current = "v1.0.0"
+	return u
+}
+
+func TestApply(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		signedVersion string
+		servedVersion string
+		wantErr       error
+	}{
+		{"newer release", "v1.1.0", "v1.1.0", nil},
+		{"same release", "v1.0.0", "v1.0.0", ErrNotNewer},
+		{"older release", "v0.9.0", "v0.9.0", ErrNotNewer},
+		{"old binary passed off as new", "v0.9.0", "v1.1.0", ErrBadSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			exe := filepath.Join(t.TempDir(), "synthera")
+			if err := os.WriteFile(exe, []byte("old"), 0755); err != nil {
+				t.Fatal(err)
+			}
+
+			srv := release(t, priv, tt.signedVersion, tt.servedVersion, []byte("new"))
+			u := newTestUpdater(t, srv.URL, pub)
+			rel, err := u.Latest(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = u.Apply(context.Background(), rel, exe)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Apply = %v, want %v", err, tt.wantErr)
+			}
+
+			want := "old"
+			if tt.wantErr == nil {
+				want = "new"
+			}
+			if got, _ := os.ReadFile(exe); string(got) != want {
+				t.Errorf("binary = %q, want %q", got, want)
+			}
+			if tt.wantErr == nil {
+				if got, _ := os.ReadFile(exe + ".old"); string(got) != "old" {
+					t.Errorf("backup = %q, want the previous binary", got)
+				}
+			}
+			entries, _ := os.ReadDir(filepath.Dir(exe))
+			for _, e := range entries {
+				if name := e.Name(); name != "synthera" && name != "synthera.old" {
+					t.Errorf("left behind %s", name)
+				}
+			}
+		})
+	}
+}
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		candidate, current string
+		want               bool
+	}{
+		{"v1.2.0", "v1.1.9", true},
+		{"v1.2.0", "v1.2.0", false},
+		{"v1.2.0", "v1.10.0", false},
+		{"v1.2.0", "v1.2.0-rc1", true},
+		{"v1.2.0-rc2", "v1.2.0-rc1", true},
+		{"v1.2.0-rc1", "v1.2.0", false},
+		{"v1.0.0", "dev", true},
+		{"garbage", "v1.0.0", false},
+	}
+	for _, tt := range tests {
+		if got := Newer(tt.candidate, tt.current); got != tt.want {
+			t.Errorf("Newer(%q, %q) = %v, want %v", tt.candidate, tt.current, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,283 @@
+// Package usage aggregates search history into
+// metering and spend reports
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"synthera/api"
+)
+
+type Format string
+
+const (
+	FormatTable Format = "table"
+	FormatCSV   Format = "csv"
+	FormatJSON  Format = "json"
+)
+
+// projectionWindow is how far back spend is averaged when projecting
+// balance and subscription runway.
+const projectionWindow = 30 * 24 * time.Hour
+
+// projectionHorizon is how far ahead the balance is projected. A trickle
+// of spend would otherwise put the date past what time.Time can hold.
+const projectionHorizon = 10 * 365 * 24 * time.Hour
+
+type Bucket struct {
+	Key     string  `json:"key"`
+	Lookups int     `json:"lookups"`
+	Cost    float64 `json:"cost"`
+}
+
+type Projection struct {
+	Balance               float64    `json:"balance"`
+	DailyAverage          float64    `json:"daily_average"`
+	BalanceEmptyAt        *time.Time `json:"balance_empty_at,omitempty"`
+	SubscriptionPlan      string     `json:"subscription_plan,omitempty"`
+	SubscriptionExpiresAt *time.Time `json:"subscription_expires_at,omitempty"`
+	SpendUntilExpiry      float64    `json:"spend_until_expiry"`
+	Shortfall             float64    `json:"shortfall"`
+}
+
+// Report only carries metering data. Query strings and result summaries
+// describe the people that were looked up, so they never make it in here.
+// Partial is set when only part of the history could be read.
+type Report struct {
+	GeneratedAt time.Time  `json:"generated_at"`
+	Partial     bool       `json:"partial,omitempty"`
+	Lookups     int        `json:"lookups"`
+	TotalCost   float64    `json:"total_cost"`
+	ByType      []Bucket   `json:"by_type"`
+	ByOperator  []Bucket   `json:"by_operator"`
+	ByDay       []Bucket   `json:"by_day"`
+	ByWeek      []Bucket   `json:"by_week"`
+	Projection  Projection `json:"projection"`
+}
+
+func ParseFormat(s string) (Format, error) {
+	switch f := Format(s); f {
+	case FormatTable, FormatCSV, FormatJSON:
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want table, csv or json)", s)
+}
+
+func Build(items []api.HistoryItem, user api.User, now time.Time) Report {
+	r := Report{GeneratedAt: now}
+
+	byType := map[string]*Bucket{}
+	byOperator := map[string]*Bucket{}
+	byDay := map[string]*Bucket{}
+	byWeek := map[string]*Bucket{}
+
+	var windowCost float64
+	windowStart := now.Add(-projectionWindow)
+
+	for _, item := range items {
+		r.Lookups++
+		r.TotalCost += item.Cost
+
+		add(byType, orUnknown(item.Type), item.Cost)
+		add(byOperator, orUnknown(item.Email), item.Cost)
+		if !item.CreatedAt.IsZero() {
+			t := item.CreatedAt.In(now.Location())
+			year, week := t.ISOWeek()
+			add(byDay, t.Format("2006-01-02"), item.Cost)
+			add(byWeek, fmt.Sprintf("%d-W%02d", year, week), item.Cost)
+			if t.After(windowStart) {
+				windowCost += item.Cost
+			}
+		}
+	}
+
+	r.ByType = sortedByCost(byType)
+	r.ByOperator = sortedByCost(byOperator)
+	r.ByDay = sortedByKey(byDay)
+	r.ByWeek = sortedByKey(byWeek)
+	r.Projection = project(user, windowCost/projectionWindow.Hours()*24, now)
+	return r
+}
+
+func project(user api.User, daily float64, now time.Time) Projection {
+	p := Projection{
+		Balance:      user.Balance,
+		DailyAverage: daily,
+	}
+
+	switch {
+	case user.Balance <= 0:
+		// Already run out.
+		p.BalanceEmptyAt = &now
+	case daily > 0:
+		if left := user.Balance / daily * float64(24*time.Hour); left < float64(projectionHorizon) {
+			empty := now.Add(time.Duration(left))
+			p.BalanceEmptyAt = &empty
+		}
+	}
+
+	var sub *api.Subscription
+	for i := range user.Subscriptions {
+		s := &user.Subscriptions[i]
+		if !s.Active || s.ExpiredAt.Before(now) {
+			continue
+		}
+		if sub == nil || s.ExpiredAt.Before(sub.ExpiredAt) {
+			sub = s
+		}
+	}
+	if sub != nil {
+		expires := sub.ExpiredAt
+		p.SubscriptionPlan = sub.Plan
+		p.SubscriptionExpiresAt = &expires
+		p.SpendUntilExpiry = daily * expires.Sub(now).Hours() / 24
+		if p.SpendUntilExpiry > user.Balance {
+			p.Shortfall = p.SpendUntilExpiry - user.Balance
+		}
+	}
+	return p
+}
+
+func add(m map[string]*Bucket, key string, cost float64) {
+	b, ok := m[key]
+	if !ok {
+		b = &Bucket{Key: key}
+		m[key] = b
+	}
+	b.Lookups++
+	b.Cost += cost
+}
+
+func orUnknown(s string) string {
+	if s == "" {
+		return "unknown"
+	}
+	return s
+}
+
+func sortedByCost(m map[string]*Bucket) []Bucket {
+	out := collect(m)
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cost != out[j].Cost {
+			return out[i].Cost > out[j].Cost
+		}
+		return out[i].Key < out[j].Key
+	})
+	return out
+}
+
+func sortedByKey(m map[string]*Bucket) []Bucket {
+	out := collect(m)
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+func collect(m map[string]*Bucket) []Bucket {
+	out := make([]Bucket, 0, len(m))
+	for _, b := range m {
+		out = append(out, *b)
+	}
+	return out
+}
+
+func Write(w io.Writer, r Report, format Format) error {
+	switch format {
+	case FormatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "    ")
+		return enc.Encode(r)
+	case FormatCSV:
+		return writeCSV(w, r)
+	default:
+		return writeTable(w, r)
+	}
+}
+
+func sections(r Report) []struct {
+	Name    string
+	Buckets []Bucket
+} {
+	return []struct {
+		Name    string
+		Buckets []Bucket
+	}{
+		{"type", r.ByType},
+		{"operator", r.ByOperator},
+		{"day", r.ByDay},
+		{"week", r.ByWeek},
+	}
+}
+
+func writeTable(w io.Writer, r Report) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if r.Partial {
+		fmt.Fprintf(tw, "Partial\thistory too long, not all of it was read\n")
+	}
+	fmt.Fprintf(tw, "Lookups\t%d\n", r.Lookups)
+	fmt.Fprintf(tw, "Total cost\t%.2f\n", r.TotalCost)
+
+	for _, sec := range sections(r) {
+		fmt.Fprintf(tw, "\nBy %s\tLookups\tCost\n", sec.Name)
+		for _, b := range sec.Buckets {
+			fmt.Fprintf(tw, "%s\t%d\t%.2f\n", b.Key, b.Lookups, b.Cost)
+		}
+	}
+
+	p := r.Projection
+	fmt.Fprintf(tw, "\nProjection\t\n")
+	fmt.Fprintf(tw, "Balance\t%.2f\n", p.Balance)
+	fmt.Fprintf(tw, "Daily average (30d)\t%.2f\n", p.DailyAverage)
+	if p.BalanceEmptyAt != nil {
+		fmt.Fprintf(tw, "Balance runs out\t%s\n", p.BalanceEmptyAt.Format("2006-01-02"))
+	}
+	if p.SubscriptionExpiresAt != nil {
+		fmt.Fprintf(tw, "Subscription\t%s, expires %s\n", p.SubscriptionPlan, p.SubscriptionExpiresAt.Format("2006-01-02"))
+		fmt.Fprintf(tw, "Spend until expiry\t%.2f\n", p.SpendUntilExpiry)
+		if p.Shortfall > 0 {
+			fmt.Fprintf(tw, "Shortfall\t%.2f\n", p.Shortfall)
+		}
+	}
+	return tw.Flush()
+}
+
+func writeCSV(w io.Writer, r Report) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"section", "key", "lookups", "cost"})
+	if r.Partial {
+		cw.Write([]string{"total", "partial", "", ""})
+	}
+	cw.Write([]string{"total", "all", strconv.Itoa(r.Lookups), money(r.TotalCost)})
+
+	for _, sec := range sections(r) {
+		for _, b := range sec.Buckets {
+			cw.Write([]string{sec.Name, b.Key, strconv.Itoa(b.Lookups), money(b.Cost)})
+		}
+	}
+
+	p := r.Projection
+	cw.Write([]string{"projection", "balance", "", money(p.Balance)})
+	cw.Write([]string{"projection", "daily_average", "", money(p.DailyAverage)})
+	if p.BalanceEmptyAt != nil {
+		cw.Write([]string{"projection", "balance_empty_at", "", p.BalanceEmptyAt.Format(time.RFC3339)})
+	}
+	if p.SubscriptionExpiresAt != nil {
+		cw.Write([]string{"projection", "subscription_expires_at", "", p.SubscriptionExpiresAt.Format(time.RFC3339)})
+		cw.Write([]string{"projection", "spend_until_expiry", "", money(p.SpendUntilExpiry)})
+		cw.Write([]string{"projection", "shortfall", "", money(p.Shortfall)})
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+func money(v float64) string {
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
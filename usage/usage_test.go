@@ -0,0 +1,164 @@
+package usage
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"synthera/api"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 3, 4, 12, 0, 0, 0, time.UTC)
+
+func lookup(kind, email string, cost float64, age time.Duration) api.HistoryItem {
+	return api.HistoryItem{Type: kind, Email: email, Cost: cost, CreatedAt: now.Add(-age)}
+}
+
+func TestBuild(t *testing.T) {
+	day := 24 * time.Hour
+	subscribed := func(balance float64) api.User {
+		return api.User{Balance: balance, Subscriptions: []api.Subscription{
+			{Plan: "old", Active: true, ExpiredAt: now.Add(-day)},
+			{Plan: "monthly", Active: true, ExpiredAt: now.Add(10 * day)},
+		}}
+	}
+
+	tests := []struct {
+		name      string
+		items     []api.HistoryItem
+		user      api.User
+		lookups   int
+		total     float64
+		daily     float64
+		emptyAt   *time.Time
+		untilExp  float64
+		shortfall float64
+	}{
+		{
+			name: "empty history",
+			user: api.User{Balance: 100},
+		},
+		{
+			name:    "single day",
+			items:   []api.HistoryItem{lookup("name", "a@x", 10, time.Hour), lookup("nric", "b@x", 20, 2*time.Hour), lookup("name", "a@x", 30, 3*time.Hour)},
+			user:    api.User{Balance: 100},
+			lookups: 3,
+			total:   60,
+			daily:   2,
+			emptyAt: ptr(now.Add(50 * day)),
+		},
+		{
+			name:     "no spend in the window",
+			items:    []api.HistoryItem{lookup("name", "a@x", 30, 40*day), lookup("name", "a@x", 0, time.Hour)},
+			user:     subscribed(100),
+			lookups:  2,
+			total:    30,
+			untilExp: 0,
+		},
+		{
+			name:    "spend too small to run out",
+			items:   []api.HistoryItem{lookup("name", "a@x", 1e-12, time.Hour)},
+			user:    api.User{Balance: 1e6},
+			lookups: 1,
+			total:   1e-12,
+			daily:   1e-12 / 30,
+		},
+		{
+			name:      "balance exhausted",
+			items:     []api.HistoryItem{lookup("name", "a@x", 30, time.Hour)},
+			user:      subscribed(0),
+			lookups:   1,
+			total:     30,
+			daily:     1,
+			emptyAt:   &now,
+			untilExp:  10,
+			shortfall: 10,
+		},
+		{
+			name:      "balance overdrawn, no spend",
+			user:      subscribed(-5),
+			emptyAt:   &now,
+			shortfall: 5,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := Build(tt.items, tt.user, now)
+			p := r.Projection
+			if r.Lookups != tt.lookups || !near(r.TotalCost, tt.total) {
+				t.Errorf("lookups, total = %d, %v, want %d, %v", r.Lookups, r.TotalCost, tt.lookups, tt.total)
+			}
+			if !near(p.DailyAverage, tt.daily) {
+				t.Errorf("daily average = %v, want %v", p.DailyAverage, tt.daily)
+			}
+			switch {
+			case tt.emptyAt == nil && p.BalanceEmptyAt != nil:
+				t.Errorf("balance runs out %v, want no date", p.BalanceEmptyAt)
+			case tt.emptyAt != nil && (p.BalanceEmptyAt == nil || !p.BalanceEmptyAt.Equal(*tt.emptyAt)):
+				t.Errorf("balance runs out %v, want %v", p.BalanceEmptyAt, tt.emptyAt)
+			}
+			if !near(p.SpendUntilExpiry, tt.untilExp) || !near(p.Shortfall, tt.shortfall) {
+				t.Errorf("spend until expiry, shortfall = %v, %v, want %v, %v", p.SpendUntilExpiry, p.Shortfall, tt.untilExp, tt.shortfall)
+			}
+			if p.SubscriptionExpiresAt != nil && p.SubscriptionPlan != "monthly" {
+				t.Errorf("projected against %q, want the earliest active plan", p.SubscriptionPlan)
+			}
+
+			// Every format has to cope, JSON included, which can't hold
+			// NaN or infinity.
+			for _, f := range []Format{FormatTable, FormatCSV, FormatJSON} {
+				if err := Write(&bytes.Buffer{}, r, f); err != nil {
+					t.Errorf("Write %s: %v", f, err)
+				}
+			}
+		})
+	}
+}
+
+func TestBuildBuckets(t *testing.T) {
+	items := []api.HistoryItem{
+		lookup("name", "a@x", 1, time.Hour),
+		lookup("nric", "b@x", 5, time.Hour),
+		lookup("name", "", 1, 8*24*time.Hour),
+		{Type: "name", Email: "a@x", Cost: 1},
+	}
+	r := Build(items, api.User{}, now)
+
+	want := []Bucket{{"nric", 1, 5}, {"name", 3, 3}}
+	if len(r.ByType) != 2 || r.ByType[0] != want[0] || r.ByType[1] != want[1] {
+		t.Errorf("by type = %v, want %v", r.ByType, want)
+	}
+	if len(r.ByOperator) != 3 || r.ByOperator[2].Key != "unknown" {
+		t.Errorf("by operator = %v, want the unnamed operator as unknown", r.ByOperator)
+	}
+	// The undated item counts in the totals but not by day or week.
+	if len(r.ByDay) != 2 || r.ByDay[0].Key != "2026-02-24" || r.ByDay[1].Key != "2026-03-04" {
+		t.Errorf("by day = %v", r.ByDay)
+	}
+	if len(r.ByWeek) != 2 || r.ByWeek[0].Key != "2026-W09" || r.ByWeek[1].Key != "2026-W10" {
+		t.Errorf("by week = %v", r.ByWeek)
+	}
+}
+
+func TestWritePartial(t *testing.T) {
+	r := Build(nil, api.User{}, now)
+	r.Partial = true
+	for _, f := range []Format{FormatTable, FormatCSV, FormatJSON} {
+		var out bytes.Buffer
+		if err := Write(&out, r, f); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(strings.ToLower(out.String()), "partial") {
+			t.Errorf("%s report doesn't say it is partial:\n%s", f, out.String())
+		}
+	}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,11 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd || windows)
+
+package utils
+
+import "os"
+
+// Platforms without flock, such as Solaris and AIX, fall back to the
+// atomic rename alone.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// CurrentConfigVersion is the schema version this build writes. Bump it
+// and append to migrations whenever the shape of Config changes.
+const CurrentConfigVersion = 1
+
+// migrations[i] upgrades a raw config from version i to i+1. They work on
+// the decoded JSON object so fields this build does not know about survive.
+var migrations = []func(raw map[string]json.RawMessage) error{
+	// v0 files only held api_token and had no version field.
+	func(raw map[string]json.RawMessage) error { return nil },
+}
+
+// configFields are the JSON keys Config owns. Any other key in the file
+// belongs to a newer build and is written back untouched.
+var configFields = func() []string {
+	var names []string
+	t := reflect.TypeFor[Config]()
+	for i := range t.NumField() {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			names = append(names, name)
+		}
+	}
+	return names
+}()
+
+var ErrConfigTooNew = errors.New("config was written by a newer version of synthera")
+
+// UpdateConfig does a locked read-modify-write of the config file. fn sees
+// the full current Config; anything it does not touch is written back as is.
+func UpdateConfig(fn func(*Config) error) error {
+	return updateConfig(fn, false)
+}
+
+// updateConfig is UpdateConfig, optionally shredding the old file once its
+// replacement is in place, for when fn removes a secret.
+func updateConfig(fn func(*Config) error, shredOld bool) error {
+	configPath, err := getConfigFilePath()
+	if err != nil {
+		return err
+	}
+
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to lock config: %w", err)
+	}
+	defer unlock()
+
+	cfg, raw, err := readConfig(configPath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if cfg == nil {
+		cfg = &Config{}
+		raw = map[string]json.RawMessage{}
+	}
+
+	if err := fn(cfg); err != nil {
+		return err
+	}
+	cfg.Version = CurrentConfigVersion
+
+	known, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(known, &fields); err != nil {
+		return err
+	}
+	// Fields fn emptied are left out under omitempty, so drop every key
+	// Config owns before merging, or the old value would survive.
+	for _, k := range configFields {
+		delete(raw, k)
+	}
+	for k, v := range fields {
+		raw[k] = v
+	}
+
+	data, err := json.MarshalIndent(raw, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(configPath, data, 0600, shredOld)
+}
+
+// readConfig loads and migrates the config at path. The raw object is
+// returned alongside so callers can write unknown fields back untouched.
+func readConfig(path string) (*Config, map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if raw == nil {
+		raw = map[string]json.RawMessage{}
+	}
+
+	var version int
+	if v, ok := raw["version"]; ok {
+		if err := json.Unmarshal(v, &version); err != nil {
+			return nil, nil, fmt.Errorf("invalid config version: %w", err)
+		}
+	}
+	if version > CurrentConfigVersion {
+		return nil, nil, fmt.Errorf("%w (schema %d, this build understands %d)", ErrConfigTooNew, version, CurrentConfigVersion)
+	}
+
+	for ; version < CurrentConfigVersion; version++ {
+		if err := migrations[version](raw); err != nil {
+			return nil, nil, fmt.Errorf("failed to migrate config from version %d: %w", version, err)
+		}
+	}
+	raw["version"] = json.RawMessage(fmt.Sprint(version))
+
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return nil, nil, err
+	}
+	var cfg Config
+	if err := json.Unmarshal(migrated, &cfg); err != nil {
+		return nil, nil, err
+	}
+	return &cfg, raw, nil
+}
+
+// lockConfig takes an advisory lock on a sidecar file so two running
+// instances serialise their writes. The config itself is replaced by
+// rename, so it cannot carry the lock.
+func lockConfig(configPath string) (func(), error) {
+	f, err := os.OpenFile(configPath+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place, so a crash mid-write never leaves a truncated config.
+// With shredOld the file being replaced is held open across the rename and
+// zeroed through that handle afterwards, so path itself is never the
+// zeroed copy.
+func writeFileAtomic(path string, data []byte, perm os.FileMode, shredOld bool) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	defer os.Remove(tmpPath)
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+
+	var old *os.File
+	if shredOld {
+		old, _ = os.OpenFile(path, os.O_WRONLY, 0)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		if old == nil {
+			return err
+		}
+		// Windows won't replace a file that is open. Shredding is best
+		// effort, so give it up rather than the update.
+		old.Close()
+		old = nil
+		if err := os.Rename(tmpPath, path); err != nil {
+			return err
+		}
+	}
+	if old != nil {
+		shred(old)
+		old.Close()
+	}
+	return nil
+}
+
+// shred overwrites f with zeros in place. Renaming a new file over it only
+// unlinks the old inode, leaving its contents in free blocks. This is best
+// effort: journaling and copy-on-write filesystems and SSD wear levelling
+// may still keep a copy.
+func shred(f *os.File) error {
+	st, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(make([]byte, st.Size())); err != nil {
+		return err
+	}
+	return f.Sync()
+}
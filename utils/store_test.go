@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useConfig points the package at a config file in a temporary directory
+// holding contents, or no file when contents is empty.
+func useConfig(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), ".token.json")
+	if contents != "" {
+		if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SetConfigPath(path)
+	t.Cleanup(func() { SetConfigPath("") })
+	return path
+}
+
+func readRaw(t *testing.T, path string) map[string]json.RawMessage {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatal(err)
+	}
+	return raw
+}
+
+func TestWriteFileAtomicShredsOld(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows gives up shredding a file it can't replace while open")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".token.json")
+	if err := os.WriteFile(path, []byte(`{"api_token":"secret"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// A second link keeps the old inode reachable after the rename.
+	old := filepath.Join(dir, "old")
+	if err := os.Link(path, old); err != nil {
+		t.Skip("hard links unsupported:", err)
+	}
+
+	if err := writeFileAtomic(path, []byte(`{}`), 0600, true); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != `{}` {
+		t.Errorf("config = %q, want the new contents", got)
+	}
+	got, _ := os.ReadFile(old)
+	if len(got) == 0 || !bytes.Equal(got, make([]byte, len(got))) {
+		t.Errorf("old file = %q, want zeros", got)
+	}
+}
+
+func TestUpdateConfig(t *testing.T) {
+	path := useConfig(t, `{"version": 1, "api_token": "old", "base_url": "https://example.com", "added_later": {"x": 1}}`)
+
+	err := UpdateConfig(func(c *Config) error {
+		if c.BaseURL != "https://example.com" {
+			t.Errorf("fn saw base_url %q, want the stored one", c.BaseURL)
+		}
+		c.APIToken = "new"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateConfig: %v", err)
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.APIToken != "new" || cfg.BaseURL != "https://example.com" {
+		t.Errorf("config = %+v, want the new token and the old base URL", cfg)
+	}
+	var kept bytes.Buffer
+	if err := json.Compact(&kept, readRaw(t, path)["added_later"]); err != nil || kept.String() != `{"x":1}` {
+		t.Errorf("unknown field = %s, want it kept", kept.String())
+	}
+
+	err = UpdateConfig(func(c *Config) error {
+		c.BaseURL = ""
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readRaw(t, path)["base_url"]; ok {
+		t.Error("base_url emptied by fn was written back")
+	}
+
+	failed := errors.New("no")
+	err = UpdateConfig(func(c *Config) error {
+		c.APIToken = "discarded"
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Errorf("UpdateConfig = %v, want fn's error", err)
+	}
+	if cfg, _ := LoadConfig(); cfg.APIToken != "new" {
+		t.Errorf("token after a failed update = %q, want it unchanged", cfg.APIToken)
+	}
+}
+
+func TestUpdateConfigCreates(t *testing.T) {
+	path := useConfig(t, "")
+	if err := SaveCredentials("tok", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readRaw(t, path)["version"]); got != "1" {
+		t.Errorf("version = %s, want %d", got, CurrentConfigVersion)
+	}
+	if st, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && st.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", st.Mode().Perm())
+	}
+}
+
+func TestConfigMigration(t *testing.T) {
+	// Version 0 files had no version field.
+	path := useConfig(t, `{"api_token": "tok"}`)
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig: %v", err)
+	}
+	if cfg.Version != CurrentConfigVersion || cfg.APIToken != "tok" {
+		t.Errorf("migrated config = %+v", cfg)
+	}
+
+	if err := UpdateConfig(func(*Config) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(readRaw(t, path)["version"]); got != "1" {
+		t.Errorf("stored version = %s, want %d", got, CurrentConfigVersion)
+	}
+}
+
+func TestConfigTooNew(t *testing.T) {
+	contents := `{"version": 99, "api_token": "tok"}`
+	path := useConfig(t, contents)
+
+	if _, err := LoadConfig(); !errors.Is(err, ErrConfigTooNew) {
+		t.Errorf("LoadConfig = %v, want ErrConfigTooNew", err)
+	}
+	if err := SaveCredentials("other", ""); !errors.Is(err, ErrConfigTooNew) {
+		t.Errorf("SaveCredentials = %v, want ErrConfigTooNew", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != contents {
+		t.Errorf("config = %s, want it left alone", got)
+	}
+}
+
+func TestUpdateConfigLocks(t *testing.T) {
+	if runtime.GOOS == "solaris" || runtime.GOOS == "aix" {
+		t.Skip("no advisory locks on", runtime.GOOS)
+	}
+	path := useConfig(t, `{"version": 1}`)
+
+	unlock, err := lockConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- SaveCredentials("waited", "") }()
+	select {
+	case err := <-done:
+		t.Fatalf("update finished while the config was locked: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlock()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	// Concurrent read-modify-writes must not lose each other's changes.
+	const writers = 20
+	var wg sync.WaitGroup
+	for range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := UpdateConfig(func(c *Config) error {
+				c.Anomaly.NRICBurst++
+				return nil
+			})
+			if err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Anomaly.NRICBurst != writers || cfg.APIToken != "waited" {
+		t.Errorf("after %d concurrent updates config = %+v", writers, cfg)
+	}
+}
+
+func TestSaveCredentials(t *testing.T) {
+	path := useConfig(t, `{"version": 1, "api_token": "old", "signing_secret": "abcd"}`)
+
+	if err := SaveCredentials("rotated", "ef01"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _ := LoadConfig(); cfg.APIToken != "rotated" || cfg.SigningSecret != "ef01" {
+		t.Errorf("config = %+v, want the new token and secret", cfg)
+	}
+
+	if err := SaveCredentials("unsigned", ""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := readRaw(t, path)["signing_secret"]; ok {
+		t.Error("old signing secret kept for an unsigned token")
+	}
+}
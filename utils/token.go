@@ -3,22 +3,114 @@
 package utils
 
 import (
-	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"time"
 )
 
 type Config struct {
-	APIToken string `json:"api_token"`
+	Version  int           `json:"version"`
+	APIToken string        `json:"api_token"`
+	Network  NetworkConfig `json:"network,omitempty"`
+	// SigningSecret is the hex HMAC secret paired with APIToken. When set,
+	// every request is signed so a captured one cannot be replayed.
+	SigningSecret string `json:"signing_secret,omitempty"`
+
+	CheckUpdates bool   `json:"check_updates,omitempty"`
+	ReleaseURL   string `json:"release_url,omitempty"`
+	BaseURL      string `json:"base_url,omitempty"`
+
+	// Mouse turns mouse and touch input in the TUI on or off. It is a
+	// pointer so that a missing key means on.
+	Mouse *bool `json:"mouse,omitempty"`
+	// Watermark stamps record screens with the operator, time and session.
+	// Like Mouse, a missing key means on; an admin policy can force it on.
+	Watermark *bool `json:"watermark,omitempty"`
+	// RulesFile holds acceptable-use rules for backends that don't serve
+	// any. It must be signed with the key built in as rules.PublicKey, and
+	// never replaces rules the server returns.
+	RulesFile string `json:"rules_file,omitempty"`
+	// Anomaly tunes the unusual-activity warnings.
+	Anomaly AnomalyConfig `json:"anomaly,omitempty"`
+	// AUP records each acceptable-use policy version accepted here.
+	AUP []AUPAcceptance `json:"aup,omitempty"`
+	// Onboarded is set once the first-run walkthrough has been seen.
+	Onboarded bool `json:"onboarded,omitempty"`
+}
+
+func (c *Config) MouseEnabled() bool {
+	return c.Mouse == nil || *c.Mouse
+}
+
+func (c *Config) WatermarkEnabled() bool {
+	return c.Watermark == nil || *c.Watermark
+}
+
+// AnomalyConfig tunes the warnings shown for unusual lookup patterns.
+// Zero values keep the defaults, and the org policy can override values
+// looser than it allows. Report also sends each one to the org admins,
+// which the policy may require regardless.
+type AnomalyConfig struct {
+	WindowMinutes int  `json:"window_minutes,omitempty"`
+	NRICBurst     int  `json:"nric_burst,omitempty"`
+	RepeatLookups int  `json:"repeat_lookups,omitempty"`
+	RelationPages int  `json:"relation_pages,omitempty"`
+	Report        bool `json:"report,omitempty"`
+}
+
+type AUPAcceptance struct {
+	UserID     int       `json:"user_id"`
+	Version    string    `json:"version"`
+	AcceptedAt time.Time `json:"accepted_at"`
+}
+
+type NetworkConfig struct {
+	Proxy         string   `json:"proxy,omitempty"`
+	CABundle      string   `json:"ca_bundle,omitempty"`
+	MinTLSVersion string   `json:"min_tls_version,omitempty"`
+	PinnedKeys    []string `json:"pinned_keys,omitempty"`
 }
 
 var configFileName = ".token.json"
 
+// Profile names the set of credentials in use. Profiles other than the
+// default live in .token.<profile>.json next to the default file.
+var Profile = "default"
+
+var configPathOverride string
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+func SetProfile(name string) error {
+	if !profileName.MatchString(name) {
+		return fmt.Errorf("invalid profile name %q, use letters, digits, - and _", name)
+	}
+	Profile = name
+	return nil
+}
+
+// SetConfigPath makes every load and save use path, regardless of profile.
+func SetConfigPath(path string) {
+	configPathOverride = path
+}
+
+func ConfigPath() (string, error) {
+	return getConfigFilePath()
+}
+
 func getConfigFilePath() (string, error) {
+	if configPathOverride != "" {
+		return configPathOverride, nil
+	}
 	dir, err := os.Getwd()
 	if err != nil {
 		return "", err
 	}
+	if Profile != "default" {
+		return filepath.Join(dir, ".token."+Profile+".json"), nil
+	}
 	return filepath.Join(dir, configFileName), nil
 }
 
@@ -28,33 +120,30 @@ func LoadConfig() (*Config, error) {
 		return nil, err
 	}
 
-	data, err := os.ReadFile(configPath)
+	cfg, _, err := readConfig(configPath)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, err
-		}
-		return  nil, err
-	}
-
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
-	return &cfg, nil
+	return cfg, nil
 }
 
-func SaveToken(token string) error {
-	cfg := Config{
-		APIToken: token,
-	}
-	configPath, err := getConfigFilePath()
-	if err != nil {
-		return err
-	}
+// SaveCredentials stores a token and the signing secret that goes with it,
+// shredding the file that held the old ones. An empty secret is a token
+// that isn't signed, so any secret stored for the old token is dropped.
+func SaveCredentials(token, secret string) error {
+	return updateConfig(func(cfg *Config) error {
+		cfg.APIToken = token
+		cfg.SigningSecret = secret
+		return nil
+	}, true)
+}
 
-	data, err := json.MarshalIndent(cfg, "", "    ")
-	if err != nil {
-		return err
-	}
-	return os.WriteFile(configPath, data, 0600)
+// ClearCredentials removes the token and its signing secret, shredding the
+// file that held them. Other settings are kept.
+func ClearCredentials() error {
+	return updateConfig(func(cfg *Config) error {
+		cfg.APIToken = ""
+		cfg.SigningSecret = ""
+		return nil
+	}, true)
 }
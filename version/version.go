@@ -0,0 +1,72 @@
+// Package version holds build metadata
+// injected at link time
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is set by build.sh through
+// -ldflags "-X synthera/version.Version=v1.2.3".
+var Version = "dev"
+
+// BuildDate is set by build.sh the same way, as an RFC 3339 UTC time.
+// Builds without it, such as go install, fall back to the commit time.
+var BuildDate = ""
+
+type Info struct {
+	Version   string `json:"version"`
+	Revision  string `json:"revision,omitempty"`
+	Modified  bool   `json:"modified,omitempty"`
+	BuildDate string `json:"build_date,omitempty"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+	BaseURL   string `json:"base_url"`
+	Profile   string `json:"profile"`
+}
+
+// Read collects what the binary knows about itself. BaseURL and Profile
+// depend on runtime configuration, so callers fill those in.
+func Read() Info {
+	info := Info{
+		Version:   Version,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	for _, s := range bi.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			info.Revision = s.Value
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = s.Value
+			}
+		case "vcs.modified":
+			info.Modified = s.Value == "true"
+		}
+	}
+	return info
+}
+
+func (i Info) Short() string {
+	rev := i.Revision
+	if len(rev) > 12 {
+		rev = rev[:12]
+	}
+	if i.Modified {
+		rev += "-dirty"
+	}
+	if rev == "" {
+		return i.Version
+	}
+	return i.Version + " (" + rev + ")"
+}